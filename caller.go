@@ -0,0 +1,87 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	// callerUnknown is reported when the caller could not be identified.
+	callerUnknown = "unknown"
+	// callerOther is reported for callers which are not allowed, to keep cardinality bounded.
+	callerOther = "other"
+
+	spiffeScheme = "spiffe"
+)
+
+// CallerFunc identifies the client of a server-side RPC, see WithCallerLabel. It returns an empty string when the
+// caller is unknown.
+type CallerFunc func(ctx context.Context, header http.Header) string
+
+// CallerFromHeader identifies callers by a request header, for example x-caller-service. The header must be set
+// by a trusted party, such as a proxy stripping it from external requests.
+func CallerFromHeader(name string) CallerFunc {
+	return func(_ context.Context, header http.Header) string {
+		return header.Get(name)
+	}
+}
+
+// CallerFromPeerCertificate identifies callers by the client certificate of the mTLS connection, using its SPIFFE ID
+// when present and its common name otherwise. The connection state is only available when the server handler is
+// wrapped with WrapHandler.
+func CallerFromPeerCertificate() CallerFunc {
+	return func(ctx context.Context, _ http.Header) string {
+		state, ok := ctx.Value(handlerStateKey{}).(*handlerState)
+		if !ok || state.tls == nil || len(state.tls.PeerCertificates) == 0 {
+			return ""
+		}
+
+		cert := state.tls.PeerCertificates[0]
+		for _, uri := range cert.URIs {
+			if uri.Scheme == spiffeScheme {
+				return uri.String()
+			}
+		}
+		return cert.Subject.CommonName
+	}
+}
+
+type callerLabel struct {
+	fn      CallerFunc
+	allowed map[string]struct{}
+}
+
+// of returns the label value of the caller, other when it is not allowed and unknown when it is not identified.
+func (c *callerLabel) of(ctx context.Context, header http.Header) string {
+	caller := c.fn(ctx, header)
+	if caller == "" {
+		return callerUnknown
+	}
+	if _, ok := c.allowed[caller]; !ok {
+		return callerOther
+	}
+	return caller
+}
+
+// WithCallerLabel labels server-side metrics with the caller identified by fn, such as CallerFromHeader or
+// CallerFromPeerCertificate. Only the allowed callers are reported by name, others are reported as other and
+// unidentified callers as unknown. The caller is added to all vectors but the handled seconds histogram, and is
+// ignored client-side.
+func WithCallerLabel(fn CallerFunc, allowed ...string) MetricsOption {
+	return func(opts *metricsOptions) {
+		if opts.side != sideServer {
+			return
+		}
+
+		opts.caller = &callerLabel{fn: fn, allowed: make(map[string]struct{}, len(allowed))}
+		for _, caller := range allowed {
+			opts.caller.allowed[caller] = struct{}{}
+		}
+
+		for _, dims := range []*[]Label{&opts.labels.started, &opts.labels.handled, &opts.labels.errorDetails} {
+			if !containsLabel(*dims, LabelCaller) {
+				*dims = append(*dims, LabelCaller)
+			}
+		}
+	}
+}
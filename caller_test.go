@@ -0,0 +1,136 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptor_WithCallerLabel(t *testing.T) {
+	reg := prom.NewRegistry()
+	serverMetrics := NewServerMetrics(WithCallerLabel(CallerFromHeader("x-caller-service"), "billing"))
+	reg.MustRegister(serverMetrics)
+
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(serverMetrics))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	for _, caller := range []string{"billing", "billing", "search", ""} {
+		req := connect.NewRequest(&greet.GreetRequest{Name: "elza"})
+		if caller != "" {
+			req.Header().Set("x-caller-service", caller)
+		}
+		_, err := client.Greet(context.Background(), req)
+		require.NoError(t, err)
+	}
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP connect_server_handled_total Total number of RPCs handled server-side
+		# TYPE connect_server_handled_total counter
+		connect_server_handled_total{caller="billing",code="ok",method="Greet",service="greet.v1.GreetService",type="unary"} 2
+		connect_server_handled_total{caller="other",code="ok",method="Greet",service="greet.v1.GreetService",type="unary"} 1
+		connect_server_handled_total{caller="unknown",code="ok",method="Greet",service="greet.v1.GreetService",type="unary"} 1
+	`), "connect_server_handled_total")
+	require.NoError(t, err)
+
+	greetSnapshot, ok := serverMetrics.Snapshot().Procedure(greetconnect.GreetServiceName, "Greet")
+	require.True(t, ok)
+	require.EqualValues(t, 4, greetSnapshot.Started)
+	require.EqualValues(t, 4, greetSnapshot.Handled())
+}
+
+func TestInterceptor_WithCallerLabel_PeerCertificate(t *testing.T) {
+	for name, scenario := range map[string]struct {
+		cert     *x509.Certificate
+		expected string
+	}{
+		"common name": {
+			cert:     &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}},
+			expected: "billing",
+		},
+		"spiffe id": {
+			cert: &x509.Certificate{
+				Subject: pkix.Name{CommonName: "billing"},
+				URIs:    []*url.URL{{Scheme: "spiffe", Host: "example.org", Path: "/ns/prod/sa/search"}},
+			},
+			expected: "spiffe://example.org/ns/prod/sa/search",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reg := prom.NewRegistry()
+			serverMetrics := NewServerMetrics(WithCallerLabel(CallerFromPeerCertificate(), "billing", "spiffe://example.org/ns/prod/sa/search"))
+			reg.MustRegister(serverMetrics)
+
+			interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(serverMetrics))
+
+			_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor))
+			srv := httptest.NewUnstartedServer(WrapHandler(handler, WithServerMetrics(serverMetrics)))
+			srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+			srv.StartTLS()
+			defer srv.Close()
+
+			httpClient := srv.Client()
+			httpClient.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{selfSignedCertificate(t, scenario.cert)}
+
+			client := greetconnect.NewGreetServiceClient(httpClient, srv.URL)
+			_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+			require.NoError(t, err)
+
+			err = testutil.GatherAndCompare(reg, strings.NewReader(`
+				# HELP connect_server_started_total Total number of RPCs started handling server-side
+				# TYPE connect_server_started_total counter
+				connect_server_started_total{caller="`+scenario.expected+`",method="Greet",service="greet.v1.GreetService",type="unary"} 1
+			`), "connect_server_started_total")
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWithCallerLabel_IgnoredClientSide(t *testing.T) {
+	metrics := NewClientMetrics(WithCallerLabel(CallerFromHeader("x-caller-service"), "billing"))
+	metrics.ReportStarted("unary", "greet.v1.GreetService", "Greet")
+
+	require.NoError(t, testutil.CollectAndCompare(metrics.requestStarted, strings.NewReader(`
+		# HELP connect_client_started_total Total number of RPCs started handling client-side
+		# TYPE connect_client_started_total counter
+		connect_client_started_total{method="Greet",service="greet.v1.GreetService",type="unary"} 1
+	`)))
+}
+
+func selfSignedCertificate(t *testing.T, template *x509.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template.SerialNumber = big.NewInt(1)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,94 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	compressionIdentity = "identity"
+
+	headerContentEncoding        = "Content-Encoding"
+	headerConnectContentEncoding = "Connect-Content-Encoding"
+	headerGrpcEncoding           = "Grpc-Encoding"
+	headerContentLength          = "Content-Length"
+	headerContentType            = "Content-Type"
+
+	// envelopePrefixLength is the size of the flags and length prefixing each message of enveloped protocols.
+	envelopePrefixLength = 5
+)
+
+// compressionOf returns the compression algorithm used for a message body, based on the headers set by
+// the Connect unary, Connect streaming and gRPC protocols. Uncompressed bodies are reported as identity.
+func compressionOf(header http.Header) string {
+	if header == nil {
+		return compressionIdentity
+	}
+
+	for _, key := range []string{headerContentEncoding, headerConnectContentEncoding, headerGrpcEncoding} {
+		if encoding := header.Get(key); encoding != "" {
+			return strings.ToLower(encoding)
+		}
+	}
+
+	return compressionIdentity
+}
+
+// compressionRatioOf computes the ratio of the compressed body size to the uncompressed message size. The
+// uncompressed size is the size of the message encoded as protobuf, JSON bodies are skipped.
+func compressionRatioOf(header http.Header, compressed int64, uncompressed int) (float64, bool) {
+	if header == nil || compressed <= 0 || uncompressed == 0 {
+		return 0, false
+	}
+
+	if strings.Contains(header.Get(headerContentType), "json") {
+		return 0, false
+	}
+
+	return float64(compressed) / float64(uncompressed), true
+}
+
+// receivedBodyOf returns the headers, compressed body size and uncompressed message size of the body received by
+// this side of the RPC. Server-side this is the request, client-side it is the response. The compressed size is 0
+// when it is not known.
+func receivedBodyOf(ctx context.Context, e *Event) (http.Header, int64, int) {
+	if !e.Spec.IsClient {
+		return e.RequestHeader, requestBodySizeOf(ctx, e.RequestHeader), e.RequestSize()
+	}
+
+	if e.ResponseHeader == nil {
+		return nil, 0, 0
+	}
+
+	return e.ResponseHeader, contentLengthOf(e.ResponseHeader), e.ResponseSize()
+}
+
+// requestBodySizeOf returns the compressed size of a unary request body. It is the Content-Length when the client
+// set one, which the Connect protocol does for unary calls sent with a buffered body. Otherwise, it is the size read
+// by WrapHandler, without the envelope prefix of the gRPC and Connect streaming protocols.
+func requestBodySizeOf(ctx context.Context, header http.Header) int64 {
+	if size := contentLengthOf(header); size > 0 {
+		return size
+	}
+
+	size, ok := requestBytesOf(ctx)
+	if !ok {
+		return 0
+	}
+
+	contentType := header.Get(headerContentType)
+	if strings.HasPrefix(contentType, "application/grpc") || strings.HasPrefix(contentType, "application/connect+") {
+		size -= envelopePrefixLength
+	}
+	return size
+}
+
+func contentLengthOf(header http.Header) int64 {
+	size, err := strconv.ParseInt(header.Get(headerContentLength), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
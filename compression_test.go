@@ -0,0 +1,153 @@
+package connect_go_prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+type greetServer struct {
+	greetconnect.UnimplementedGreetServiceHandler
+}
+
+func (greetServer) Greet(_ context.Context, req *connect.Request[greet.GreetRequest]) (*connect.Response[greet.GreetResponse], error) {
+	return connect.NewResponse(&greet.GreetResponse{
+		Greeting: "Hello " + req.Msg.GetName(),
+	}), nil
+}
+
+func TestCompressionOf(t *testing.T) {
+	for _, s := range []struct {
+		Name     string
+		Header   http.Header
+		Expected string
+	}{
+		{Name: "nil header", Header: nil, Expected: "identity"},
+		{Name: "no encoding", Header: http.Header{}, Expected: "identity"},
+		{Name: "connect unary", Header: http.Header{"Content-Encoding": []string{"gzip"}}, Expected: "gzip"},
+		{Name: "connect streaming", Header: http.Header{"Connect-Content-Encoding": []string{"br"}}, Expected: "br"},
+		{Name: "grpc", Header: http.Header{"Grpc-Encoding": []string{"GZIP"}}, Expected: "gzip"},
+	} {
+		t.Run(s.Name, func(t *testing.T) {
+			require.Equal(t, s.Expected, compressionOf(s.Header))
+		})
+	}
+}
+
+func TestCompressionRatioOf(t *testing.T) {
+	size := proto.Size(&greet.GreetRequest{Name: strings.Repeat("elza", 10)})
+
+	ratio, ok := compressionRatioOf(http.Header{}, 21, size)
+	require.True(t, ok)
+	require.InDelta(t, 0.5, ratio, 0.001)
+
+	_, ok = compressionRatioOf(http.Header{}, 0, size)
+	require.False(t, ok, "must not report ratio without compressed size")
+
+	_, ok = compressionRatioOf(http.Header{"Content-Type": []string{"application/json"}}, 21, size)
+	require.False(t, ok, "must not report ratio for json bodies")
+}
+
+func TestRequestBodySizeOf(t *testing.T) {
+	state := &handlerState{}
+	state.requestBytes.Store(30)
+	ctx := context.WithValue(context.Background(), handlerStateKey{}, state)
+
+	require.EqualValues(t, 21, requestBodySizeOf(ctx, http.Header{"Content-Length": []string{"21"}}))
+	require.EqualValues(t, 30, requestBodySizeOf(ctx, http.Header{"Content-Type": []string{"application/proto"}}))
+	require.EqualValues(t, 25, requestBodySizeOf(ctx, http.Header{"Content-Type": []string{"application/grpc"}}), "without the envelope prefix")
+	require.Zero(t, requestBodySizeOf(context.Background(), http.Header{}), "unknown without WrapHandler")
+}
+
+func TestInterceptor_WithCompression(t *testing.T) {
+	reg := prom.NewRegistry()
+
+	clientMetrics := NewClientMetrics(WithCompression(true))
+	serverMetrics := NewServerMetrics(WithCompression(true))
+	reg.MustRegister(clientMetrics, serverMetrics)
+
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(serverMetrics))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor), connect.WithSendGzip())
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{
+		Name: strings.Repeat("elza", 100),
+	}))
+	require.NoError(t, err)
+
+	require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.compression.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "gzip")))
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.compression.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "gzip")))
+
+	// The connect-go client streams the request body, so without WrapHandler the server only knows the
+	// compressed size when the caller sets a Content-Length.
+	count, err := testutil.GatherAndCount(reg, "connect_client_compression_ratio")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	body, err := proto.Marshal(&greet.GreetRequest{Name: strings.Repeat("elza", 100)})
+	require.NoError(t, err)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	httpReq, err := http.NewRequest(http.MethodPost, srv.URL+"/"+greetconnect.GreetServiceName+"/Greet", &compressed)
+	require.NoError(t, err)
+	httpReq.Header.Set("Content-Type", "application/proto")
+	httpReq.Header.Set("Content-Encoding", "gzip")
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	require.NoError(t, err)
+	require.NoError(t, httpResp.Body.Close())
+	require.Equal(t, http.StatusOK, httpResp.StatusCode)
+
+	count, err = testutil.GatherAndCount(reg, "connect_server_compression_ratio")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+func TestWrapHandler_CompressionRatio(t *testing.T) {
+	for name, clientOpts := range map[string][]connect.ClientOption{
+		"connect": {connect.WithSendGzip()},
+		"grpc":    {connect.WithSendGzip(), connect.WithGRPC()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			serverMetrics := NewServerMetrics(WithCompression(true))
+			interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(serverMetrics))
+
+			_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor))
+			srv := httptest.NewUnstartedServer(WrapHandler(handler, WithServerMetrics(serverMetrics)))
+			srv.EnableHTTP2 = true
+			srv.StartTLS()
+			defer srv.Close()
+
+			client := greetconnect.NewGreetServiceClient(srv.Client(), srv.URL, clientOpts...)
+			_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{
+				Name: strings.Repeat("elza", 100),
+			}))
+			require.NoError(t, err)
+
+			require.Equal(t, 1, testutil.CollectAndCount(serverMetrics.compressionRatio))
+			var metric dto.Metric
+			require.NoError(t, serverMetrics.compressionRatio.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "gzip").(prom.Metric).Write(&metric))
+			require.EqualValues(t, 1, metric.GetHistogram().GetSampleCount())
+			require.Less(t, metric.GetHistogram().GetSampleSum(), 0.2, "repeated names compress well")
+		})
+	}
+}
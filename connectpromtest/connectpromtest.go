@@ -0,0 +1,296 @@
+// Package connectpromtest provides assertions for testing services instrumented with connect-go-prometheus.
+//
+// Assertions look metrics up by their name after any namespace and subsystem prefix, and by their labels, so they
+// keep working with namespaces, subsystems and const labels configured through MetricsOption. Metrics renamed with
+// WithGRPCNaming, the name options or the label options are matched with GRPCNaming, Names and Labels.
+package connectpromtest
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// UpdateEnv is the environment variable which, when set to 1, makes AssertGolden rewrite golden files.
+const UpdateEnv = "CONNECTPROMTEST_UPDATE"
+
+// Option narrows down which metrics an assertion looks at.
+type Option func(*options)
+
+type options struct {
+	side   string
+	names  func(side string) names
+	labels labels
+}
+
+type names struct {
+	started, handled, handledSeconds string
+}
+
+type labels struct {
+	service, method, code string
+}
+
+func defaultOptions() *options {
+	return &options{
+		names: func(side string) names {
+			prefix := "connect_" + side + "_"
+			return names{
+				started:        prefix + "started_total",
+				handled:        prefix + "handled_total",
+				handledSeconds: prefix + "handled_seconds",
+			}
+		},
+		labels: labels{service: "service", method: "method", code: "code"},
+	}
+}
+
+// Server only asserts on server-side metrics.
+func Server() Option {
+	return func(o *options) {
+		o.side = "server"
+	}
+}
+
+// Client only asserts on client-side metrics.
+func Client() Option {
+	return func(o *options) {
+		o.side = "client"
+	}
+}
+
+// GRPCNaming matches metrics named with WithGRPCNaming. Codes are then given by their gRPC name, for example OK.
+func GRPCNaming() Option {
+	return func(o *options) {
+		o.names = func(side string) names {
+			prefix := "grpc_" + side + "_"
+			return names{
+				started:        prefix + "started_total",
+				handled:        prefix + "handled_total",
+				handledSeconds: prefix + "handling_seconds",
+			}
+		}
+		o.labels = labels{service: "grpc_service", method: "grpc_method", code: "grpc_code"}
+	}
+}
+
+// Names matches metrics renamed with WithStartedName, WithHandledName and WithHandledSecondsName. The names are
+// given without namespace and subsystem, and are the same for both sides.
+func Names(started, handled, handledSeconds string) Option {
+	return func(o *options) {
+		o.names = func(string) names {
+			return names{started: started, handled: handled, handledSeconds: handledSeconds}
+		}
+	}
+}
+
+// Labels matches metrics with labels renamed with WithServiceLabel, WithMethodLabel and WithCodeLabel.
+func Labels(service, method, code string) Option {
+	return func(o *options) {
+		o.labels = labels{service: service, method: method, code: code}
+	}
+}
+
+// AssertStarted asserts that n RPCs of the procedure, for example /greet.v1.GreetService/Greet, were started.
+// Without Server or Client, counts of both sides are summed.
+func AssertStarted(t testing.TB, reg prom.Gatherer, procedure string, n int, opts ...Option) {
+	t.Helper()
+
+	got := sum(t, reg, func(n names) string { return n.started }, procedure, "", opts...)
+	if got != float64(n) {
+		t.Errorf("expected %d started RPCs of %s, got %v", n, procedure, got)
+	}
+}
+
+// AssertHandled asserts that n RPCs of the procedure were handled with the code, for example ok or not_found.
+// Without Server or Client, counts of both sides are summed.
+func AssertHandled(t testing.TB, reg prom.Gatherer, procedure, code string, n int, opts ...Option) {
+	t.Helper()
+
+	got := sum(t, reg, func(n names) string { return n.handled }, procedure, code, opts...)
+	if got != float64(n) {
+		t.Errorf("expected %d handled RPCs of %s with code %s, got %v", n, procedure, code, got)
+	}
+}
+
+// AssertLatencyObserved asserts that the latency of n RPCs of the procedure was observed, across all codes.
+// The histogram must be enabled with WithHistogram.
+func AssertLatencyObserved(t testing.TB, reg prom.Gatherer, procedure string, n int, opts ...Option) {
+	t.Helper()
+
+	got := sum(t, reg, func(n names) string { return n.handledSeconds }, procedure, "", opts...)
+	if got != float64(n) {
+		t.Errorf("expected %d latency observations of %s, got %v", n, procedure, got)
+	}
+}
+
+// AssertGolden compares the exposition of reg, restricted to metricNames when given, with the golden file at path.
+// Histogram sums and buckets depend on timing, so their values are normalized. Run the test with
+// CONNECTPROMTEST_UPDATE=1 to write the golden file.
+func AssertGolden(t testing.TB, reg prom.Gatherer, path string, metricNames ...string) {
+	t.Helper()
+
+	got := Exposition(t, reg, metricNames...)
+
+	if os.Getenv(UpdateEnv) == "1" {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("failed to write golden file %s: %v", path, err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file %s, run with %s=1 to create it: %v", path, UpdateEnv, err)
+	}
+
+	if string(expected) != got {
+		t.Errorf("exposition does not match golden file %s, run with %s=1 to update it.\nexpected:\n%s\ngot:\n%s", path, UpdateEnv, expected, got)
+	}
+}
+
+// Exposition returns the normalized text exposition of reg, restricted to metricNames when given.
+func Exposition(t testing.TB, reg prom.Gatherer, metricNames ...string) string {
+	t.Helper()
+
+	families := gather(t, reg)
+
+	var buf bytes.Buffer
+	for _, family := range families {
+		if len(metricNames) > 0 && !contains(metricNames, family.GetName()) {
+			continue
+		}
+		if _, err := expfmt.MetricFamilyToText(&buf, family); err != nil {
+			t.Fatalf("failed to encode metric family %s: %v", family.GetName(), err)
+		}
+	}
+
+	return normalize(buf.String(), families)
+}
+
+var sampleValue = regexp.MustCompile(`\s\S+$`)
+
+// normalize replaces values of histogram sums and buckets with a placeholder.
+func normalize(exposition string, families []*dto.MetricFamily) string {
+	var volatile []string
+	for _, family := range families {
+		if family.GetType() == dto.MetricType_HISTOGRAM {
+			volatile = append(volatile, family.GetName()+"_sum", family.GetName()+"_bucket")
+		}
+	}
+
+	lines := strings.Split(exposition, "\n")
+	for i, line := range lines {
+		for _, prefix := range volatile {
+			if strings.HasPrefix(line, prefix+"{") || strings.HasPrefix(line, prefix+" ") {
+				lines[i] = sampleValue.ReplaceAllString(line, " <volatile>")
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sum adds up the values of the metric picked from names, matching the procedure and, when given, the code.
+func sum(t testing.TB, reg prom.Gatherer, pick func(names) string, procedure, code string, opts ...Option) float64 {
+	t.Helper()
+
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	sides := []string{"server", "client"}
+	if o.side != "" {
+		sides = []string{o.side}
+	}
+	wanted := map[string]bool{}
+	for _, side := range sides {
+		wanted[pick(o.names(side))] = true
+	}
+
+	service, method := splitProcedure(procedure)
+
+	var total float64
+	for _, family := range gather(t, reg) {
+		if !isWanted(family.GetName(), wanted) {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			if !matches(metric, o.labels, service, method, code) {
+				continue
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				total += metric.GetCounter().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				total += float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return total
+}
+
+// isWanted reports whether name is one of the wanted names, optionally prefixed by a namespace and subsystem.
+func isWanted(name string, wanted map[string]bool) bool {
+	if wanted[name] {
+		return true
+	}
+	for i := strings.Index(name, "_"); i >= 0; i = strings.Index(name, "_") {
+		name = name[i+1:]
+		if wanted[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(metric *dto.Metric, l labels, service, method, code string) bool {
+	values := map[string]string{}
+	for _, pair := range metric.GetLabel() {
+		values[pair.GetName()] = pair.GetValue()
+	}
+
+	if values[l.service] != service || values[l.method] != method {
+		return false
+	}
+	return code == "" || values[l.code] == code
+}
+
+func gather(t testing.TB, reg prom.Gatherer) []*dto.MetricFamily {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+	return families
+}
+
+func splitProcedure(procedure string) (string, string) {
+	procedure = strings.TrimPrefix(procedure, "/")
+	if i := strings.Index(procedure, "/"); i >= 0 {
+		return procedure[:i], procedure[i+1:]
+	}
+	return procedure, ""
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
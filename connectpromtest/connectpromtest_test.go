@@ -0,0 +1,137 @@
+package connectpromtest_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	connect_go_prometheus "github.com/easyCZ/connect-go-prometheus"
+	"github.com/easyCZ/connect-go-prometheus/connectpromtest"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+const greetProcedure = "/greet.v1.GreetService/Greet"
+
+// recordingT records errors instead of failing the test, to assert on failing assertions.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func setup(t *testing.T, opts ...connect_go_prometheus.MetricsOption) *prom.Registry {
+	return setupSides(t, opts, opts)
+}
+
+func setupSides(t *testing.T, clientOpts, serverOpts []connect_go_prometheus.MetricsOption) *prom.Registry {
+	reg := prom.NewRegistry()
+	clientMetrics := connect_go_prometheus.NewClientMetrics(clientOpts...)
+	serverMetrics := connect_go_prometheus.NewServerMetrics(serverOpts...)
+	reg.MustRegister(clientMetrics, serverMetrics)
+
+	interceptor := connect_go_prometheus.NewInterceptor(
+		connect_go_prometheus.WithClientMetrics(clientMetrics),
+		connect_go_prometheus.WithServerMetrics(serverMetrics),
+	)
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	for i := 0; i < 2; i++ {
+		_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+		require.Error(t, err)
+	}
+
+	return reg
+}
+
+func TestAssertions(t *testing.T) {
+	reg := setup(t,
+		connect_go_prometheus.WithHistogram(true),
+		connect_go_prometheus.WithNamespace("namespace"),
+		connect_go_prometheus.WithConstLabels(prom.Labels{"component": "foo"}),
+	)
+
+	connectpromtest.AssertStarted(t, reg, greetProcedure, 4)
+	connectpromtest.AssertStarted(t, reg, greetProcedure, 2, connectpromtest.Server())
+	connectpromtest.AssertHandled(t, reg, greetProcedure, "unimplemented", 2, connectpromtest.Client())
+	connectpromtest.AssertHandled(t, reg, greetProcedure, "ok", 0)
+	connectpromtest.AssertLatencyObserved(t, reg, greetProcedure, 2, connectpromtest.Server())
+}
+
+func TestAssertions_GRPCNaming(t *testing.T) {
+	reg := setup(t, connect_go_prometheus.WithGRPCNaming(), connect_go_prometheus.WithHistogram(true))
+
+	connectpromtest.AssertStarted(t, reg, greetProcedure, 4, connectpromtest.GRPCNaming())
+	connectpromtest.AssertHandled(t, reg, greetProcedure, "Unimplemented", 2, connectpromtest.GRPCNaming(), connectpromtest.Server())
+	connectpromtest.AssertLatencyObserved(t, reg, greetProcedure, 2, connectpromtest.GRPCNaming(), connectpromtest.Client())
+}
+
+func TestAssertions_CustomNames(t *testing.T) {
+	opts := func(subsystem string) []connect_go_prometheus.MetricsOption {
+		return []connect_go_prometheus.MetricsOption{
+			connect_go_prometheus.WithSubsystem(subsystem),
+			connect_go_prometheus.WithHistogram(true),
+			connect_go_prometheus.WithStartedName("rpcs_started_total"),
+			connect_go_prometheus.WithHandledName("rpcs_handled_total"),
+			connect_go_prometheus.WithHandledSecondsName("rpc_duration_seconds"),
+			connect_go_prometheus.WithServiceLabel("rpc_service"),
+			connect_go_prometheus.WithMethodLabel("rpc_method"),
+			connect_go_prometheus.WithCodeLabel("rpc_code"),
+		}
+	}
+	reg := setupSides(t, opts("client"), opts("server"))
+	assertOpts := []connectpromtest.Option{
+		connectpromtest.Names("rpcs_started_total", "rpcs_handled_total", "rpc_duration_seconds"),
+		connectpromtest.Labels("rpc_service", "rpc_method", "rpc_code"),
+	}
+
+	connectpromtest.AssertStarted(t, reg, greetProcedure, 4, assertOpts...)
+	connectpromtest.AssertHandled(t, reg, greetProcedure, "unimplemented", 4, assertOpts...)
+	connectpromtest.AssertLatencyObserved(t, reg, greetProcedure, 4, assertOpts...)
+}
+
+func TestAssertions_ExactName(t *testing.T) {
+	reg := setup(t)
+	retries := prom.NewCounterVec(prom.CounterOpts{Name: "connect_client_retry_started_total"}, []string{"service", "method"})
+	retries.WithLabelValues("greet.v1.GreetService", "Greet").Add(3)
+	reg.MustRegister(retries)
+
+	connectpromtest.AssertStarted(t, reg, greetProcedure, 2, connectpromtest.Client())
+}
+
+func TestAssertions_Failing(t *testing.T) {
+	reg := setup(t)
+
+	rec := &recordingT{TB: t}
+	connectpromtest.AssertStarted(rec, reg, greetProcedure, 1, connectpromtest.Server())
+	connectpromtest.AssertHandled(rec, reg, greetProcedure, "ok", 1)
+	connectpromtest.AssertLatencyObserved(rec, reg, greetProcedure, 2)
+
+	require.Equal(t, []string{
+		"expected 1 started RPCs of /greet.v1.GreetService/Greet, got 2",
+		"expected 1 handled RPCs of /greet.v1.GreetService/Greet with code ok, got 0",
+		"expected 2 latency observations of /greet.v1.GreetService/Greet, got 0",
+	}, rec.errors)
+}
+
+func TestAssertGolden(t *testing.T) {
+	reg := setup(t, connect_go_prometheus.WithHistogram(true), connect_go_prometheus.WithHistogramBuckets([]float64{1}))
+
+	connectpromtest.AssertGolden(t, reg, "testdata/server.golden",
+		"connect_server_started_total",
+		"connect_server_handled_total",
+		"connect_server_handled_seconds",
+	)
+}
@@ -0,0 +1,154 @@
+package connect_go_prometheus
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// seriesExpiry deletes series which have not been updated for the TTL, see WithSeriesTTL.
+type seriesExpiry struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu     sync.Mutex
+	series map[*prom.MetricVec]map[string]*seriesEntry
+}
+
+type seriesEntry struct {
+	values  []string
+	updated time.Time
+}
+
+func newSeriesExpiry(ttl time.Duration, now func() time.Time) *seriesExpiry {
+	return &seriesExpiry{
+		ttl:    ttl,
+		now:    now,
+		series: map[*prom.MetricVec]map[string]*seriesEntry{},
+	}
+}
+
+// touch records an update of the series. It is a no-op when expiry is disabled.
+func (e *seriesExpiry) touch(vec *prom.MetricVec, values []string) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	entries, ok := e.series[vec]
+	if !ok {
+		entries = map[string]*seriesEntry{}
+		e.series[vec] = entries
+	}
+
+	key := strings.Join(values, "\xff")
+	if entry, ok := entries[key]; ok {
+		entry.updated = e.now()
+		return
+	}
+	entries[key] = &seriesEntry{values: values, updated: e.now()}
+}
+
+// expire deletes the series not updated for the TTL, and returns the number of series deleted.
+func (e *seriesExpiry) expire() int {
+	if e == nil {
+		return 0
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	expired := 0
+	now := e.now()
+	for vec, entries := range e.series {
+		for key, entry := range entries {
+			if now.Sub(entry.updated) < e.ttl {
+				continue
+			}
+			vec.DeleteLabelValues(entry.values...)
+			delete(entries, key)
+			expired++
+		}
+	}
+	return expired
+}
+
+// forget drops the entries of the vector whose values match, by position in the values, for example once their
+// series were deleted.
+func (e *seriesExpiry) forget(vec *prom.MetricVec, match map[int]string) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	entries := e.series[vec]
+	for key, entry := range entries {
+		if matchesValues(entry.values, match) {
+			delete(entries, key)
+		}
+	}
+}
+
+func matchesValues(values []string, match map[int]string) bool {
+	for i, value := range match {
+		if i >= len(values) || values[i] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *seriesExpiry) reset() {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.series = map[*prom.MetricVec]map[string]*seriesEntry{}
+}
+
+func (m *Metrics) inc(vec *prom.CounterVec, values []string) {
+	m.expiry.touch(vec.MetricVec, values)
+	vec.WithLabelValues(values...).Inc()
+}
+
+func (m *Metrics) add(vec *prom.CounterVec, values []string, val float64) {
+	m.expiry.touch(vec.MetricVec, values)
+	vec.WithLabelValues(values...).Add(val)
+}
+
+func (m *Metrics) observe(vec *prom.HistogramVec, values []string, val float64) {
+	m.expiry.touch(vec.MetricVec, values)
+	vec.WithLabelValues(values...).Observe(val)
+}
+
+// ExpireStale deletes the series which have not been updated for the TTL configured with WithSeriesTTL, and returns
+// the number of series deleted. Stale series are also expired on every collection.
+func (m *Metrics) ExpireStale() int {
+	return m.expiry.expire()
+}
+
+// WithSeriesTTL deletes series of RPCs which have not been updated for the TTL, to bound the number of series of
+// long-running processes handling many procedures or label values. Expired counters restart from zero when updated
+// again. By default, series never expire.
+func WithSeriesTTL(ttl time.Duration) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.seriesTTL = ttl
+	}
+}
+
+// WithClock replaces the clock of time-based metrics, series expiry and endpoint health, for example with a fake
+// clock in tests. By default, time.Now is used.
+func WithClock(now func() time.Time) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.now = now
+	}
+}
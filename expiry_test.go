@@ -0,0 +1,50 @@
+package connect_go_prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_WithSeriesTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	metrics := NewServerMetrics(WithSeriesTTL(time.Minute), WithClock(clock.Now))
+
+	metrics.ReportStarted("unary", "greet.v1.GreetService", "Greet")
+	metrics.ReportStarted("unary", "greet.v1.GreetService", "Farewell")
+	metrics.ReportRejected("unknown_procedure", "404")
+
+	clock.Advance(30 * time.Second)
+	metrics.ReportStarted("unary", "greet.v1.GreetService", "Greet")
+	require.Zero(t, metrics.ExpireStale())
+
+	// Farewell was not updated for the TTL, Greet was updated 30s ago.
+	clock.Advance(30 * time.Second)
+	require.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+		# HELP connect_server_started_total Total number of RPCs started handling server-side
+		# TYPE connect_server_started_total counter
+		connect_server_started_total{method="Greet",service="greet.v1.GreetService",type="unary"} 2
+	`), "connect_server_started_total"))
+	require.Equal(t, 1, testutil.CollectAndCount(metrics.rejected), "rejected series do not expire")
+
+	clock.Advance(30 * time.Second)
+	require.Equal(t, 2, metrics.ExpireStale(), "started and msg sent of Greet")
+	require.Zero(t, testutil.CollectAndCount(metrics.requestStarted))
+
+	// Expired series restart from zero.
+	metrics.ReportStarted("unary", "greet.v1.GreetService", "Greet")
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.requestStarted.WithLabelValues("unary", "greet.v1.GreetService", "Greet")))
+}
+
+func TestMetrics_WithoutSeriesTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	metrics := NewServerMetrics(WithClock(clock.Now))
+	metrics.ReportStarted("unary", "greet.v1.GreetService", "Greet")
+
+	clock.Advance(24 * time.Hour)
+	require.Zero(t, metrics.ExpireStale())
+	require.Equal(t, 1, testutil.CollectAndCount(metrics.requestStarted))
+}
@@ -0,0 +1,56 @@
+package connect_go_prometheus
+
+import (
+	"strings"
+)
+
+// WithGRPCNaming names metrics and labels compatible with go-grpc-prometheus, to keep existing dashboards and
+// alerts working when migrating from grpc-go:
+//   - grpc_{server,client}_started_total, grpc_{server,client}_handled_total, grpc_{server,client}_handling_seconds,
+//     grpc_{server,client}_msg_sent_total and grpc_{server,client}_msg_received_total
+//   - grpc_type, grpc_service, grpc_method and grpc_code labels, the handling histogram is not labelled by code
+//   - gRPC code names, such as OK and NotFound, and bidi_stream for bidirectional streams
+//   - unary requests counted as messages when the RPC starts, and responses only once successfully sent
+func WithGRPCNaming() MetricsOption {
+	return func(opts *metricsOptions) {
+		prefix := "grpc_" + opts.side + "_"
+		opts.requestStartedName = prefix + "started_total"
+		opts.requestHandledName = prefix + "handled_total"
+		opts.requestHandledSecondsName = prefix + "handling_seconds"
+		opts.streamMsgSentName = prefix + "msg_sent_total"
+		opts.streamMsgReceivedName = prefix + "msg_received_total"
+
+		opts.labels.typeName = "grpc_type"
+		opts.labels.serviceName = "grpc_service"
+		opts.labels.methodName = "grpc_method"
+		opts.labels.codeName = "grpc_code"
+		opts.labels.formatType = grpcTypeOf
+		opts.labels.formatCode = grpcCodeOf
+		opts.labels.histogram = without(opts.labels.histogram, []Label{LabelCode})
+		opts.grpcMessages = true
+	}
+}
+
+func grpcTypeOf(callType string) string {
+	if callType == "bidi" {
+		return "bidi_stream"
+	}
+	return callType
+}
+
+// grpcCodeOf converts a connect code, for example not_found, to its gRPC name, NotFound.
+func grpcCodeOf(code string) string {
+	if code == "ok" {
+		return "OK"
+	}
+
+	var b strings.Builder
+	for _, word := range strings.Split(code, "_") {
+		if word == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]))
+		b.WriteString(word[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGRPCCodeOf(t *testing.T) {
+	for code, expected := range map[string]string{
+		"ok":                  "OK",
+		"canceled":            "Canceled",
+		"not_found":           "NotFound",
+		"deadline_exceeded":   "DeadlineExceeded",
+		"failed_precondition": "FailedPrecondition",
+		"unauthenticated":     "Unauthenticated",
+	} {
+		require.Equal(t, expected, grpcCodeOf(code))
+	}
+}
+
+func TestInterceptor_WithGRPCNaming(t *testing.T) {
+	reg := prom.NewRegistry()
+	clientMetrics := NewClientMetrics(WithGRPCNaming())
+	serverMetrics := NewServerMetrics(WithGRPCNaming(), WithHistogram(true), WithHistogramBuckets([]float64{1}))
+	reg.MustRegister(clientMetrics, serverMetrics)
+
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(serverMetrics))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.Error(t, err)
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP grpc_client_handled_total Total number of RPCs handled client-side
+		# TYPE grpc_client_handled_total counter
+		grpc_client_handled_total{grpc_code="Unimplemented",grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="unary"} 1
+		# HELP grpc_client_started_total Total number of RPCs started handling client-side
+		# TYPE grpc_client_started_total counter
+		grpc_client_started_total{grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="unary"} 1
+		# HELP grpc_server_handled_total Total number of RPCs handled server-side
+		# TYPE grpc_server_handled_total counter
+		grpc_server_handled_total{grpc_code="Unimplemented",grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="unary"} 1
+		# HELP grpc_server_started_total Total number of RPCs started handling server-side
+		# TYPE grpc_server_started_total counter
+		grpc_server_started_total{grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="unary"} 1
+		# HELP grpc_server_msg_received_total Total number of stream messages recieved by server-side
+		# TYPE grpc_server_msg_received_total counter
+		grpc_server_msg_received_total{grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="unary"} 1
+	`),
+		"grpc_client_started_total", "grpc_client_handled_total",
+		"grpc_server_started_total", "grpc_server_handled_total",
+		"grpc_server_msg_received_total",
+	)
+	require.NoError(t, err)
+
+	count, err := testutil.GatherAndCount(reg, "grpc_server_handling_seconds", "grpc_client_msg_sent_total")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	// As with go-grpc-prometheus, responses of failed unary RPCs are not counted as messages.
+	count, err = testutil.GatherAndCount(reg, "grpc_server_msg_sent_total", "grpc_client_msg_received_total")
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	greetSnapshot, ok := serverMetrics.Snapshot().Procedure(greetconnect.GreetServiceName, "Greet")
+	require.True(t, ok)
+	code, ok := greetSnapshot.Code("Unimplemented")
+	require.True(t, ok)
+	require.EqualValues(t, 1, code.Handled)
+	require.EqualValues(t, 1, greetSnapshot.Latency.Count, "latency must be reported across codes")
+}
+
+func TestInterceptor_WithGRPCNaming_Messages(t *testing.T) {
+	reg := prom.NewRegistry()
+	clientMetrics := NewClientMetrics(WithGRPCNaming())
+	serverMetrics := NewServerMetrics(WithGRPCNaming())
+	reg.MustRegister(clientMetrics, serverMetrics)
+
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(serverMetrics))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP grpc_client_msg_received_total Total number of stream messages recieved by client-side
+		# TYPE grpc_client_msg_received_total counter
+		grpc_client_msg_received_total{grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="unary"} 1
+		# HELP grpc_client_msg_sent_total Total number of stream messages sent by client-side
+		# TYPE grpc_client_msg_sent_total counter
+		grpc_client_msg_sent_total{grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="unary"} 1
+		# HELP grpc_server_msg_received_total Total number of stream messages recieved by server-side
+		# TYPE grpc_server_msg_received_total counter
+		grpc_server_msg_received_total{grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="unary"} 1
+		# HELP grpc_server_msg_sent_total Total number of stream messages sent by server-side
+		# TYPE grpc_server_msg_sent_total counter
+		grpc_server_msg_sent_total{grpc_method="Greet",grpc_service="greet.v1.GreetService",grpc_type="unary"} 1
+	`), "grpc_client_msg_sent_total", "grpc_client_msg_received_total", "grpc_server_msg_sent_total", "grpc_server_msg_received_total"))
+}
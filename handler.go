@@ -0,0 +1,172 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bufbuild/connect-go"
+)
+
+// WrapHandler instruments a server http.Handler, typically the mux connect handlers are mounted on, to count
+// requests rejected before they reach the Interceptor. These are unknown procedures, unsupported content types,
+// bad compression, oversized bodies and protocol errors, which connect-go answers before running any interceptor.
+//
+// Rejections are reported to the server metrics configured with WithServerMetrics, by default DefaultServerMetrics.
+// WrapHandler also counts the bytes of request bodies, so compression ratios of streamed request bodies, without a
+// Content-Length, are observed, see WithCompression.
+// Handlers must also use the Interceptor, otherwise every failed request is counted as rejected.
+func WrapHandler(handler http.Handler, opts ...InterecptorOption) http.Handler {
+	options := evaluteInterceptorOptions(&interceptorOptions{
+		client: DefaultClientMetrics,
+		server: DefaultServerMetrics,
+	}, opts...)
+
+	if options.server == nil && options.serverRouter == nil {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := &handlerState{tls: r.TLS}
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		if r.Body != nil {
+			r.Body = &countingBody{ReadCloser: r.Body, n: &state.requestBytes}
+		}
+
+		handler.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), handlerStateKey{}, state)))
+
+		if state.intercepted.Load() {
+			return
+		}
+
+		reason, ok := rejectionReasonOf(rw.status, rw.Header())
+		if !ok {
+			return
+		}
+
+		metrics := options.server
+		if options.serverRouter != nil {
+			metrics = options.serverRouter(connect.Spec{Procedure: r.URL.Path}, r.Header)
+		}
+		if metrics != nil {
+			metrics.ReportRejected(reason, strconv.Itoa(rw.status))
+		}
+	})
+}
+
+type handlerStateKey struct{}
+
+// handlerState is shared between WrapHandler and the Interceptor through the request context.
+type handlerState struct {
+	intercepted atomic.Bool
+	// requestBytes is the size of the request body read so far, as sent on the wire.
+	requestBytes atomic.Int64
+	// tls is the connection state of the request, used to identify callers by their client certificate.
+	tls *tls.ConnectionState
+}
+
+// requestBytesOf returns the size of the request body read so far, when the request went through WrapHandler.
+func requestBytesOf(ctx context.Context) (int64, bool) {
+	state, ok := ctx.Value(handlerStateKey{}).(*handlerState)
+	if !ok {
+		return 0, false
+	}
+	return state.requestBytes.Load(), true
+}
+
+// markIntercepted records that a request reached the server-side Interceptor.
+func markIntercepted(ctx context.Context) {
+	if state, ok := ctx.Value(handlerStateKey{}).(*handlerState); ok {
+		state.intercepted.Store(true)
+	}
+}
+
+// rejectionReasonOf classifies a response written without reaching the Interceptor. The connect-go handler maps
+// errors to HTTP statuses for the Connect protocol, and to the Grpc-Status trailer for gRPC.
+func rejectionReasonOf(status int, header http.Header) (string, bool) {
+	switch status {
+	case http.StatusNotFound:
+		// The mux answers unknown procedures in plain text, connect-go answers unimplemented compression with JSON.
+		if strings.HasPrefix(header.Get(headerContentType), "application/json") {
+			return "bad_compression", true
+		}
+		return "unknown_procedure", true
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed", true
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_content_type", true
+	case http.StatusRequestEntityTooLarge:
+		return "body_too_large", true
+	case http.StatusTooManyRequests:
+		// Connect maps resource_exhausted to 429, for oversized bodies but also for RPCs shed by an interceptor
+		// installed ahead of the Interceptor, such as the Limiter.
+		return "resource_exhausted", true
+	}
+
+	grpcStatus := header.Get("Grpc-Status")
+	if grpcStatus == "" {
+		grpcStatus = header.Get(http.TrailerPrefix + "Grpc-Status")
+	}
+
+	switch grpcStatus {
+	case "", "0":
+	case "8": // resource_exhausted, see above
+		return "resource_exhausted", true
+	case "12": // unimplemented
+		return "bad_compression", true
+	default:
+		return "protocol_error", true
+	}
+
+	if status >= http.StatusBadRequest {
+		return "protocol_error", true
+	}
+
+	return "", false
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// statusRecorder captures the status code written by the wrapped handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,83 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapHandler(t *testing.T) {
+	reg := prom.NewRegistry()
+	serverMetrics := NewServerMetrics()
+	reg.MustRegister(serverMetrics)
+
+	interceptor := NewInterceptor(WithServerMetrics(serverMetrics), WithClientMetrics(nil))
+
+	mux := http.NewServeMux()
+	mux.Handle(greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor)))
+	srv := httptest.NewServer(WrapHandler(mux, WithServerMetrics(serverMetrics), WithClientMetrics(nil)))
+	defer srv.Close()
+
+	post := func(path, contentType, contentEncoding string) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader("{}"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		if contentEncoding != "" {
+			req.Header.Set("Content-Encoding", contentEncoding)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	greetPath := "/" + greetconnect.GreetServiceName + "/Greet"
+	post("/unknown.v1.Service/Method", "application/json", "")
+	post(greetPath, "text/plain", "")
+	post(greetPath, "application/json", "zstd")
+
+	// Requests reaching the interceptor are not rejections.
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+
+	err = testutil.CollectAndCompare(serverMetrics.rejected, strings.NewReader(`
+		# HELP connect_server_rejected_total Total number of requests rejected server-side before reaching the interceptor
+		# TYPE connect_server_rejected_total counter
+		connect_server_rejected_total{http_status="404",reason="bad_compression"} 1
+		connect_server_rejected_total{http_status="404",reason="unknown_procedure"} 1
+		connect_server_rejected_total{http_status="415",reason="unsupported_content_type"} 1
+	`))
+	require.NoError(t, err)
+}
+
+func TestRejectionReasonOf(t *testing.T) {
+	for _, s := range []struct {
+		Status   int
+		Header   http.Header
+		Reason   string
+		Rejected bool
+	}{
+		{Status: http.StatusOK, Header: http.Header{}, Rejected: false},
+		{Status: http.StatusOK, Header: http.Header{"Grpc-Status": []string{"0"}}, Rejected: false},
+		{Status: http.StatusOK, Header: http.Header{"Grpc-Status": []string{"12"}}, Reason: "bad_compression", Rejected: true},
+		{Status: http.StatusOK, Header: http.Header{"Grpc-Status": []string{"8"}}, Reason: "resource_exhausted", Rejected: true},
+		{Status: http.StatusOK, Header: http.Header{"Grpc-Status": []string{"13"}}, Reason: "protocol_error", Rejected: true},
+		{Status: http.StatusMethodNotAllowed, Header: http.Header{}, Reason: "method_not_allowed", Rejected: true},
+		{Status: http.StatusRequestEntityTooLarge, Header: http.Header{}, Reason: "body_too_large", Rejected: true},
+		{Status: http.StatusTooManyRequests, Header: http.Header{}, Reason: "resource_exhausted", Rejected: true},
+		{Status: http.StatusBadRequest, Header: http.Header{}, Reason: "protocol_error", Rejected: true},
+	} {
+		reason, ok := rejectionReasonOf(s.Status, s.Header)
+		require.Equal(t, s.Rejected, ok)
+		require.Equal(t, s.Reason, reason)
+	}
+}
@@ -0,0 +1,205 @@
+package connect_go_prometheus
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// healthErrorCodes are the codes counted as errors of an endpoint, rather than of the request.
+	healthErrorCodes = map[string]struct{}{
+		connect.CodeUnknown.String():           {},
+		connect.CodeDeadlineExceeded.String():  {},
+		connect.CodeResourceExhausted.String(): {},
+		connect.CodeInternal.String():          {},
+		connect.CodeUnavailable.String():       {},
+		connect.CodeDataLoss.String():          {},
+	}
+)
+
+// healthMinWeight is the weight below which an endpoint is forgotten, reached about 4.6 windows after a single RPC.
+const healthMinWeight = 0.01
+
+// EndpointHealth is the recent error rate and latency of the RPCs sent to an endpoint, weighted exponentially by
+// recency, see WithEndpointHealth.
+type EndpointHealth struct {
+	// Target is the host RPCs were sent to, as in the base URL of the client.
+	Target string
+	// ErrorRate is the ratio of RPCs which failed with unknown, deadline_exceeded, resource_exhausted, internal,
+	// unavailable or data_loss.
+	ErrorRate float64
+	// Latency is the mean latency of RPCs.
+	Latency time.Duration
+	// Weight is the number of recent RPCs the scores are based on, decayed by age. Scores based on a low weight
+	// are less reliable.
+	Weight float64
+}
+
+type endpointStats struct {
+	updated time.Time
+	weight  float64
+	errors  float64
+	seconds float64
+}
+
+// decay ages the stats to now, so older RPCs weigh less than recent ones.
+func (s *endpointStats) decay(now time.Time, window time.Duration) {
+	factor := math.Exp(-float64(now.Sub(s.updated)) / float64(window))
+	s.weight *= factor
+	s.errors *= factor
+	s.seconds *= factor
+	s.updated = now
+}
+
+func (s *endpointStats) health(target string) EndpointHealth {
+	h := EndpointHealth{Target: target, Weight: s.weight}
+	if s.weight > 0 {
+		h.ErrorRate = s.errors / s.weight
+		h.Latency = time.Duration(s.seconds / s.weight * float64(time.Second))
+	}
+	return h
+}
+
+// endpointHealth keeps exponentially weighted moving averages of error rate and latency per endpoint client-side.
+type endpointHealth struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	endpoints map[string]*endpointStats
+
+	errorRate *prom.Desc
+	latency   *prom.Desc
+}
+
+func newEndpointHealth(config *metricsOptions) *endpointHealth {
+	gauge := func(name, help string) *prom.Desc {
+		return prom.NewDesc(prom.BuildFQName(config.namespace, config.subsystem, name), help, []string{"target"}, config.constLabels)
+	}
+
+	return &endpointHealth{
+		window:    config.healthWindow,
+		now:       config.now,
+		endpoints: map[string]*endpointStats{},
+		errorRate: gauge(config.healthErrorRateName, "Exponentially weighted moving average of the error rate of RPCs per endpoint client-side"),
+		latency:   gauge(config.healthLatencyName, "Exponentially weighted moving average of the latency of RPCs per endpoint client-side"),
+	}
+}
+
+func (h *endpointHealth) observe(target, code string, duration time.Duration) {
+	if target == "" {
+		return
+	}
+
+	h.mu.Lock()
+	stats, ok := h.endpoints[target]
+	if !ok {
+		stats = &endpointStats{}
+		h.endpoints[target] = stats
+	}
+	stats.decay(h.now(), h.window)
+	stats.weight++
+	stats.seconds += duration.Seconds()
+	if _, ok := healthErrorCodes[code]; ok {
+		stats.errors++
+	}
+	h.mu.Unlock()
+}
+
+// decayAll ages the stats of all endpoints to now, and forgets the endpoints no longer sent RPCs to. The lock must
+// be held.
+func (h *endpointHealth) decayAll() {
+	now := h.now()
+	for target, stats := range h.endpoints {
+		stats.decay(now, h.window)
+		if stats.weight < healthMinWeight {
+			delete(h.endpoints, target)
+		}
+	}
+}
+
+func (h *endpointHealth) get(target string) (EndpointHealth, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stats, ok := h.endpoints[target]
+	if !ok {
+		return EndpointHealth{}, false
+	}
+	stats.decay(h.now(), h.window)
+	if stats.weight < healthMinWeight {
+		delete(h.endpoints, target)
+		return EndpointHealth{}, false
+	}
+	return stats.health(target), true
+}
+
+func (h *endpointHealth) all() []EndpointHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.decayAll()
+	endpoints := make([]EndpointHealth, 0, len(h.endpoints))
+	for target, stats := range h.endpoints {
+		endpoints = append(endpoints, stats.health(target))
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Target < endpoints[j].Target
+	})
+	return endpoints
+}
+
+// Describe implements Describe as required by prom.Collector
+func (h *endpointHealth) Describe(c chan<- *prom.Desc) {
+	c <- h.errorRate
+	c <- h.latency
+}
+
+// Collect implements collect as required by prom.Collector. Scores are decayed to the time of the collection.
+func (h *endpointHealth) Collect(c chan<- prom.Metric) {
+	for _, health := range h.all() {
+		c <- prom.MustNewConstMetric(h.errorRate, prom.GaugeValue, health.ErrorRate, health.Target)
+		c <- prom.MustNewConstMetric(h.latency, prom.GaugeValue, health.Latency.Seconds(), health.Target)
+	}
+}
+
+// EndpointHealth returns the recent health of the endpoint RPCs were sent to, identified by its host. It is only
+// available client-side, with WithEndpointHealth.
+func (m *Metrics) EndpointHealth(target string) (EndpointHealth, bool) {
+	if m.health == nil {
+		return EndpointHealth{}, false
+	}
+	return m.health.get(target)
+}
+
+// Endpoints returns the recent health of all endpoints RPCs were sent to, sorted by target. It is only available
+// client-side, with WithEndpointHealth.
+func (m *Metrics) Endpoints() []EndpointHealth {
+	if m.health == nil {
+		return nil
+	}
+	return m.health.all()
+}
+
+// WithEndpointHealth keeps the error rate and latency of RPCs per endpoint client-side, as moving averages where
+// RPCs weigh less the older they are. The weight of an RPC decays by e after window. Scores are read with
+// Metrics.EndpointHealth, for example by a load balancer, and exported as gauges. Endpoints are forgotten once the
+// weight of their RPCs decayed below 0.01, about 4.6 windows after a single RPC.
+func WithEndpointHealth(window time.Duration) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.healthWindow = window
+	}
+}
+
+// Reset forgets all endpoints.
+func (h *endpointHealth) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.endpoints = map[string]*endpointStats{}
+}
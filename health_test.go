@@ -0,0 +1,140 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func handledEvent(target, code string, duration time.Duration) *Event {
+	return &Event{
+		Spec:     connect.Spec{Procedure: "/greet.v1.GreetService/Greet", IsClient: true},
+		Peer:     connect.Peer{Addr: target},
+		Type:     "unary",
+		Service:  "greet.v1.GreetService",
+		Method:   "Greet",
+		Code:     code,
+		Duration: duration,
+	}
+}
+
+func TestMetrics_EndpointHealth(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	metrics := NewClientMetrics(WithEndpointHealth(10*time.Second), WithClock(clock.Now))
+	ctx := context.Background()
+
+	metrics.Handled(ctx, handledEvent("a.example.com", "ok", 100*time.Millisecond))
+	metrics.Handled(ctx, handledEvent("a.example.com", "unavailable", 300*time.Millisecond))
+	metrics.Handled(ctx, handledEvent("b.example.com", "not_found", 100*time.Millisecond))
+
+	health, ok := metrics.EndpointHealth("a.example.com")
+	require.True(t, ok)
+	require.Equal(t, EndpointHealth{Target: "a.example.com", ErrorRate: 0.5, Latency: 200 * time.Millisecond, Weight: 2}, health)
+
+	health, ok = metrics.EndpointHealth("b.example.com")
+	require.True(t, ok)
+	require.Zero(t, health.ErrorRate, "not_found is not an error of the endpoint")
+
+	_, ok = metrics.EndpointHealth("c.example.com")
+	require.False(t, ok)
+
+	// Older RPCs weigh less, after the window their weight decayed by e.
+	clock.Advance(10 * time.Second)
+	metrics.Handled(ctx, handledEvent("a.example.com", "ok", 100*time.Millisecond))
+
+	health, ok = metrics.EndpointHealth("a.example.com")
+	require.True(t, ok)
+	require.InDelta(t, 2/math.E+1, health.Weight, 1e-9)
+	require.InDelta(t, 1/(2+math.E), health.ErrorRate, 1e-9)
+	require.InDelta(t, (0.4/math.E+0.1)/(2/math.E+1), health.Latency.Seconds(), 1e-6)
+
+	clock.Advance(10 * time.Second)
+	endpoints := metrics.Endpoints()
+	require.Len(t, endpoints, 2)
+	require.Equal(t, "a.example.com", endpoints[0].Target)
+	require.InDelta(t, (2/math.E+1)/math.E, endpoints[0].Weight, 1e-9)
+	require.InDelta(t, 1/(2+math.E), endpoints[0].ErrorRate, 1e-9, "reading does not change the scores")
+	require.Equal(t, "b.example.com", endpoints[1].Target)
+
+	require.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+		# HELP connect_client_endpoint_error_rate Exponentially weighted moving average of the error rate of RPCs per endpoint client-side
+		# TYPE connect_client_endpoint_error_rate gauge
+		connect_client_endpoint_error_rate{target="a.example.com"} 0.21194155761708544
+		connect_client_endpoint_error_rate{target="b.example.com"} 0
+	`), "connect_client_endpoint_error_rate"))
+}
+
+func TestMetrics_EndpointHealthForgotten(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	metrics := NewClientMetrics(WithEndpointHealth(10*time.Second), WithClock(clock.Now))
+	ctx := context.Background()
+
+	metrics.Handled(ctx, handledEvent("a.example.com", "unavailable", 100*time.Millisecond))
+	clock.Advance(50 * time.Second)
+	metrics.Handled(ctx, handledEvent("b.example.com", "ok", 100*time.Millisecond))
+
+	require.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+		# HELP connect_client_endpoint_error_rate Exponentially weighted moving average of the error rate of RPCs per endpoint client-side
+		# TYPE connect_client_endpoint_error_rate gauge
+		connect_client_endpoint_error_rate{target="b.example.com"} 0
+	`), "connect_client_endpoint_error_rate"))
+
+	_, ok := metrics.EndpointHealth("a.example.com")
+	require.False(t, ok, "the weight of a decayed below the minimum")
+	endpoints := metrics.Endpoints()
+	require.Len(t, endpoints, 1)
+	require.Equal(t, "b.example.com", endpoints[0].Target)
+}
+
+func TestMetrics_EndpointHealthDisabled(t *testing.T) {
+	metrics := NewClientMetrics()
+	metrics.Handled(context.Background(), handledEvent("a.example.com", "ok", time.Millisecond))
+
+	_, ok := metrics.EndpointHealth("a.example.com")
+	require.False(t, ok)
+	require.Empty(t, metrics.Endpoints())
+}
+
+func TestInterceptor_EndpointHealth(t *testing.T) {
+	clientMetrics := NewClientMetrics(WithEndpointHealth(time.Minute))
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(nil))
+
+	_, handler := greetconnect.NewGreetServiceHandler(failingGreetServer{err: connect.NewError(connect.CodeUnavailable, nil)})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.Error(t, err)
+
+	target, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	health, ok := clientMetrics.EndpointHealth(target.Host)
+	require.True(t, ok)
+	require.Equal(t, 1.0, health.ErrorRate)
+	require.Positive(t, health.Latency)
+}
@@ -0,0 +1,39 @@
+package connect_go_prometheus
+
+import (
+	"net/http"
+
+	"github.com/bufbuild/connect-go"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Instrument creates client and server metrics configured with opts, registered against a new registry together with
+// the Go runtime and process collectors. It returns a connect.Option installing an Interceptor which reports to
+// them, to pass to every handler and client constructor, and an http.Handler serving the registry, to mount on
+// /metrics. The handler serves the OpenMetrics format to scrapers which request it.
+//
+//	option, metricsHandler := connect_go_prometheus.Instrument(connect_go_prometheus.WithHistogram(true))
+//	mux.Handle(greetconnect.NewGreetServiceHandler(greeter, option))
+//	mux.Handle("/metrics", metricsHandler)
+func Instrument(opts ...MetricsOption) (connect.Option, http.Handler) {
+	clientMetrics := NewClientMetrics(opts...)
+	serverMetrics := NewServerMetrics(opts...)
+
+	registry := prom.NewRegistry()
+	registry.MustRegister(
+		clientMetrics,
+		serverMetrics,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(serverMetrics))
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+		Registry:          registry,
+	})
+
+	return connect.WithInterceptors(interceptor), handler
+}
@@ -0,0 +1,62 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrument(t *testing.T) {
+	option, metricsHandler := Instrument(WithHistogram(true))
+
+	mux := http.NewServeMux()
+	mux.Handle(greetconnect.NewGreetServiceHandler(greetServer{}, option))
+	mux.Handle("/metrics", metricsHandler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, option)
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+
+	for name, scenario := range map[string]struct {
+		accept      string
+		contentType string
+	}{
+		"text": {
+			contentType: "text/plain; version=0.0.4; charset=utf-8",
+		},
+		"openmetrics": {
+			accept:      "application/openmetrics-text; version=0.0.1",
+			contentType: "application/openmetrics-text; version=0.0.1; charset=utf-8",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/metrics", nil)
+			require.NoError(t, err)
+			if scenario.accept != "" {
+				req.Header.Set("Accept", scenario.accept)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, scenario.contentType, resp.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(body), `connect_server_handled_total{code="ok",method="Greet",service="greet.v1.GreetService",type="unary"} 1`)
+			require.Contains(t, string(body), `connect_client_handled_total{code="ok",method="Greet",service="greet.v1.GreetService",type="unary"} 1`)
+			require.Contains(t, string(body), `connect_server_handled_seconds_count{code="ok",method="Greet",service="greet.v1.GreetService",type="unary"} 1`)
+			require.Contains(t, string(body), "go_goroutines")
+		})
+	}
+}
@@ -2,6 +2,8 @@ package connect_go_prometheus
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -10,66 +12,140 @@ import (
 
 func NewInterceptor(opts ...InterecptorOption) *Interceptor {
 	options := evaluteInterceptorOptions(&interceptorOptions{
-		client: DefaultClientMetrics,
-		server: DefaultServerMetrics,
+		client:      DefaultClientMetrics,
+		server:      DefaultServerMetrics,
+		loggedCodes: defaultLoggedCodes,
 	}, opts...)
 
 	return &Interceptor{
-		client: options.client,
-		server: options.server,
+		client: options.clientReporters(),
+		server: options.serverReporters(),
+	}
+}
+
+// NewClientInterceptor creates an Interceptor which only reports client-side RPCs, to the metrics and the reporters
+// configured with opts. Metrics may be nil, to only report to the configured reporters. Server-side RPCs are not
+// reported, and the default metrics are not used.
+func NewClientInterceptor(metrics *Metrics, opts ...ClientInterceptorOption) *Interceptor {
+	options := &interceptorOptions{client: metrics, loggedCodes: defaultLoggedCodes}
+	for _, opt := range opts {
+		opt.apply(options)
+	}
+
+	return &Interceptor{
+		client: options.clientReporters(),
+	}
+}
+
+// NewServerInterceptor creates an Interceptor which only reports server-side RPCs, to the metrics and the reporters
+// configured with opts. Metrics may be nil, to only report to the configured reporters. Client-side RPCs are not
+// reported, and the default metrics are not used.
+func NewServerInterceptor(metrics *Metrics, opts ...ServerInterceptorOption) *Interceptor {
+	options := &interceptorOptions{server: metrics, loggedCodes: defaultLoggedCodes}
+	for _, opt := range opts {
+		opt.apply(options)
+	}
+
+	return &Interceptor{
+		server: options.serverReporters(),
 	}
 }
 
 var _ connect.Interceptor = (*Interceptor)(nil)
 
 type Interceptor struct {
-	client *Metrics
-	server *Metrics
+	client []Reporter
+	server []Reporter
 }
 
 func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 	return connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
-		// Short-circuit, not configured to report for either client or server.
-		if i.client == nil && i.server == nil {
-			return next(ctx, req)
+		if !req.Spec().IsClient {
+			markIntercepted(ctx)
 		}
 
-		now := time.Now()
-		callType := steamTypeString(req.Spec().StreamType)
-		callPackage, callMethod := procedureToPackageAndMethod(req.Spec().Procedure)
-
-		var reporter *Metrics
+		var reporters []Reporter
 		if req.Spec().IsClient {
-			reporter = i.client
+			reporters = i.client
 		} else {
-			reporter = i.server
+			reporters = i.server
 		}
 
-		if reporter != nil {
-			reporter.ReportStarted(callType, callPackage, callMethod)
+		// Short-circuit, not configured to report for this side.
+		if len(reporters) == 0 {
+			return next(ctx, req)
+		}
+
+		event := newEvent(req.Spec(), req.Peer(), req.Header(), req.Any())
+		for _, reporter := range reporters {
+			reporter.Started(ctx, event)
 		}
 
 		resp, err := next(ctx, req)
-		code := codeOf(err)
 
-		if reporter != nil {
-			reporter.ReportHandled(callType, callPackage, callMethod, code)
-			reporter.ReportHandledSeconds(callType, callPackage, callMethod, code, time.Since(now).Seconds())
+		event.handled(ctx, resp, err)
+		for _, reporter := range reporters {
+			reporter.Handled(ctx, event)
 		}
 
 		return resp, err
 	})
 }
 
-func (i *Interceptor) WrapStreamingClient(connect.StreamingClientFunc) connect.StreamingClientFunc {
-	return connect.StreamingClientFunc(func(ctx context.Context, s connect.Spec) connect.StreamingClientConn {
-		return nil
+func (i *Interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return connect.StreamingClientFunc(func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+
+		// Short-circuit, not configured to report for this side.
+		if len(i.client) == 0 {
+			return conn
+		}
+
+		event := newEvent(spec, conn.Peer(), conn.RequestHeader(), nil)
+		for _, reporter := range i.client {
+			reporter.Started(ctx, event)
+		}
+
+		counter := &streamCounter{}
+		return &reportingClientConn{
+			StreamingClientConn: conn,
+			ctx:                 ctx,
+			counter:             counter,
+			handled: func(err error) {
+				counter.end(ctx, event, err)
+				event.handledStream(ctx, conn.ResponseHeader(), err)
+				for _, reporter := range i.client {
+					reporter.Handled(ctx, event)
+				}
+			},
+		}
 	})
 }
 
-func (i *Interceptor) WrapStreamingHandler(connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
-	return connect.StreamingHandlerFunc(func(ctx context.Context, shc connect.StreamingHandlerConn) error {
-		return nil
+func (i *Interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return connect.StreamingHandlerFunc(func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		markIntercepted(ctx)
+
+		// Short-circuit, not configured to report for this side.
+		if len(i.server) == 0 {
+			return next(ctx, conn)
+		}
+
+		event := newEvent(conn.Spec(), conn.Peer(), conn.RequestHeader(), nil)
+		for _, reporter := range i.server {
+			reporter.Started(ctx, event)
+		}
+
+		counter := &streamCounter{}
+		err := next(ctx, &reportingHandlerConn{StreamingHandlerConn: conn, counter: counter})
+
+		counter.end(ctx, event, err)
+		event.handledStream(ctx, conn.ResponseHeader(), err)
+		for _, reporter := range i.server {
+			reporter.Handled(ctx, event)
+		}
+
+		return err
 	})
 }
 
@@ -101,31 +177,226 @@ func codeOf(err error) string {
 	if err == nil {
 		return "ok"
 	}
+
+	// Handlers may return context errors as is, connect-go sends them with the matching code.
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		switch {
+		case errors.Is(err, context.Canceled):
+			return connect.CodeCanceled.String()
+		case errors.Is(err, context.DeadlineExceeded):
+			return connect.CodeDeadlineExceeded.String()
+		}
+	}
 	return connect.CodeOf(err).String()
 }
 
+// rpcCodeOf returns the code an RPC was handled with. Canceled RPCs past their deadline are deadline_exceeded: the
+// client drops the connection at its deadline, which may cancel the server-side context before the server's own
+// deadline timer fires.
+func rpcCodeOf(ctx context.Context, err error) string {
+	code := codeOf(err)
+	if code == connect.CodeCanceled.String() && deadlinePassed(ctx) {
+		return connect.CodeDeadlineExceeded.String()
+	}
+	return code
+}
+
+// deadlinePassed reports whether the context has a deadline, and it has passed.
+func deadlinePassed(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// contextErrorOf converts the error of a done context to a connect error, with the canceled or deadline_exceeded code.
+func contextErrorOf(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return connect.NewError(connect.CodeDeadlineExceeded, ctx.Err())
+	}
+	return connect.NewError(connect.CodeCanceled, ctx.Err())
+}
+
+// errorDetailTypesOf returns the protobuf type names of the details attached to a connect error.
+func errorDetailTypesOf(err error) []string {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return nil
+	}
+
+	details := connectErr.Details()
+	types := make([]string, 0, len(details))
+	for _, detail := range details {
+		types = append(types, detail.Type())
+	}
+	return types
+}
+
 type interceptorOptions struct {
 	client *Metrics
 	server *Metrics
+
+	clientOtel *OtelMetrics
+	serverOtel *OtelMetrics
+
+	clientChain []Reporter
+	serverChain []Reporter
+
+	serverRouter MetricsRouter
+
+	logger        *slog.Logger
+	slowThreshold time.Duration
+	loggedCodes   []connect.Code
+}
+
+func (o *interceptorOptions) clientReporters() []Reporter {
+	return chainReporters(o.client, o.clientOtel, o.clientChain, o.logReporter())
+}
+
+func (o *interceptorOptions) serverReporters() []Reporter {
+	if o.serverRouter != nil {
+		chain := append([]Reporter{&routingReporter{router: o.serverRouter}}, o.serverChain...)
+		return chainReporters(nil, o.serverOtel, chain, o.logReporter())
+	}
+	return chainReporters(o.server, o.serverOtel, o.serverChain, o.logReporter())
 }
 
-type InterecptorOption func(*interceptorOptions)
+func (o *interceptorOptions) logReporter() Reporter {
+	if o.logger == nil {
+		return nil
+	}
+	return newLogReporter(o.logger, o.slowThreshold, o.loggedCodes)
+}
+
+func chainReporters(metrics *Metrics, otelMetrics *OtelMetrics, chain []Reporter, logger Reporter) []Reporter {
+	var reporters []Reporter
+	if metrics != nil {
+		reporters = append(reporters, metrics)
+	}
+	if otelMetrics != nil {
+		reporters = append(reporters, otelMetrics)
+	}
+	for _, reporter := range chain {
+		if reporter != nil {
+			reporters = append(reporters, reporter)
+		}
+	}
+	if logger != nil {
+		reporters = append(reporters, logger)
+	}
+	return reporters
+}
+
+// InterecptorOption configures NewInterceptor, WrapHandler and WrapTransport.
+type InterecptorOption interface {
+	apply(*interceptorOptions)
+}
+
+// ClientInterceptorOption configures client-side reporting, it is also accepted by NewClientInterceptor.
+type ClientInterceptorOption interface {
+	InterecptorOption
+	clientSide()
+}
+
+// ServerInterceptorOption configures server-side reporting, it is also accepted by NewServerInterceptor.
+type ServerInterceptorOption interface {
+	InterecptorOption
+	serverSide()
+}
+
+// SharedInterceptorOption configures reporting of both sides, it is accepted by NewClientInterceptor and
+// NewServerInterceptor.
+type SharedInterceptorOption interface {
+	ClientInterceptorOption
+	ServerInterceptorOption
+}
+
+type interceptorOption func(*interceptorOptions)
+
+func (o interceptorOption) apply(io *interceptorOptions) { o(io) }
+
+type clientInterceptorOption func(*interceptorOptions)
+
+func (o clientInterceptorOption) apply(io *interceptorOptions) { o(io) }
+func (o clientInterceptorOption) clientSide()                  {}
+
+type serverInterceptorOption func(*interceptorOptions)
+
+func (o serverInterceptorOption) apply(io *interceptorOptions) { o(io) }
+func (o serverInterceptorOption) serverSide()                  {}
+
+type sharedInterceptorOption func(*interceptorOptions)
+
+func (o sharedInterceptorOption) apply(io *interceptorOptions) { o(io) }
+func (o sharedInterceptorOption) clientSide()                  {}
+func (o sharedInterceptorOption) serverSide()                  {}
 
 func WithClientMetrics(m *Metrics) InterecptorOption {
-	return func(io *interceptorOptions) {
+	return interceptorOption(func(io *interceptorOptions) {
 		io.client = m
-	}
+	})
 }
 
 func WithServerMetrics(m *Metrics) InterecptorOption {
-	return func(io *interceptorOptions) {
+	return interceptorOption(func(io *interceptorOptions) {
 		io.server = m
-	}
+	})
+}
+
+// WithClientOtelMetrics reports client-side RPCs to OpenTelemetry, in addition to the client Metrics.
+func WithClientOtelMetrics(m *OtelMetrics) ClientInterceptorOption {
+	return clientInterceptorOption(func(io *interceptorOptions) {
+		io.clientOtel = m
+	})
+}
+
+// WithServerOtelMetrics reports server-side RPCs to OpenTelemetry, in addition to the server Metrics.
+func WithServerOtelMetrics(m *OtelMetrics) ServerInterceptorOption {
+	return serverInterceptorOption(func(io *interceptorOptions) {
+		io.serverOtel = m
+	})
+}
+
+// WithClientReporter adds a Reporter for client-side RPCs. Reporters are chained, in addition to the client Metrics.
+func WithClientReporter(r Reporter) ClientInterceptorOption {
+	return clientInterceptorOption(func(io *interceptorOptions) {
+		io.clientChain = append(io.clientChain, r)
+	})
+}
+
+// WithServerReporter adds a Reporter for server-side RPCs. Reporters are chained, in addition to the server Metrics.
+func WithServerReporter(r Reporter) ServerInterceptorOption {
+	return serverInterceptorOption(func(io *interceptorOptions) {
+		io.serverChain = append(io.serverChain, r)
+	})
+}
+
+// WithLogger emits a structured record to the logger for every RPC which is slower than the threshold configured
+// with WithSlowThreshold, or ends with one of the codes configured with WithLoggedCodes.
+func WithLogger(logger *slog.Logger) SharedInterceptorOption {
+	return sharedInterceptorOption(func(io *interceptorOptions) {
+		io.logger = logger
+	})
+}
+
+// WithSlowThreshold configures the duration from which RPCs are logged, see WithLogger. By default, slow RPCs
+// are not logged.
+func WithSlowThreshold(threshold time.Duration) SharedInterceptorOption {
+	return sharedInterceptorOption(func(io *interceptorOptions) {
+		io.slowThreshold = threshold
+	})
+}
+
+// WithLoggedCodes configures the codes of RPCs which are logged, see WithLogger. By default, RPCs ending with
+// unknown, internal, unavailable and data_loss are logged.
+func WithLoggedCodes(codes ...connect.Code) SharedInterceptorOption {
+	return sharedInterceptorOption(func(io *interceptorOptions) {
+		io.loggedCodes = codes
+	})
 }
 
 func evaluteInterceptorOptions(defaults *interceptorOptions, opts ...InterecptorOption) *interceptorOptions {
 	for _, opt := range opts {
-		opt(defaults)
+		opt.apply(defaults)
 	}
 	return defaults
 }
@@ -2,9 +2,11 @@ package connect_go_prometheus
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/bufbuild/connect-go"
 	"github.com/easyCZ/connect-go-prometheus/gen/greet"
@@ -12,6 +14,9 @@ import (
 	prom "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 var (
@@ -59,6 +64,9 @@ func TestInterceptor_WithClient_WithServer_Histogram(t *testing.T) {
 }
 
 func TestInterceptor_Default(t *testing.T) {
+	DefaultClientMetrics.Reset()
+	DefaultServerMetrics.Reset()
+
 	interceptor := NewInterceptor()
 
 	_, handler := greetconnect.NewGreetServiceHandler(greetconnect.UnimplementedGreetServiceHandler{}, connect.WithInterceptors(interceptor))
@@ -144,3 +152,103 @@ func TestInterceptor_WithServerMetrics(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 3, count, "must report only server side metrics, client-side is disabled")
 }
+
+type failingGreetServer struct {
+	greetconnect.UnimplementedGreetServiceHandler
+	err error
+}
+
+func (s failingGreetServer) Greet(context.Context, *connect.Request[greet.GreetRequest]) (*connect.Response[greet.GreetResponse], error) {
+	return nil, s.err
+}
+
+func TestInterceptor_ErrorDetails(t *testing.T) {
+	reg := prom.NewRegistry()
+
+	clientMetrics := NewClientMetrics()
+	serverMetrics := NewServerMetrics()
+	reg.MustRegister(clientMetrics, serverMetrics)
+
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(serverMetrics))
+
+	connectErr := connect.NewError(connect.CodeFailedPrecondition, errors.New("out of stock"))
+	for _, msg := range []proto.Message{durationpb.New(time.Second), wrapperspb.String("sku-1"), wrapperspb.String("sku-2")} {
+		detail, err := connect.NewErrorDetail(msg)
+		require.NoError(t, err)
+		connectErr.AddDetail(detail)
+	}
+
+	_, handler := greetconnect.NewGreetServiceHandler(failingGreetServer{err: connectErr}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{
+		Name: "elza",
+	}))
+	require.Error(t, err)
+	require.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+
+	for _, m := range []*Metrics{clientMetrics, serverMetrics} {
+		require.EqualValues(t, 1, testutil.ToFloat64(m.errorDetails.WithLabelValues(greetconnect.GreetServiceName, "Greet", "failed_precondition", "google.protobuf.Duration")))
+		require.EqualValues(t, 2, testutil.ToFloat64(m.errorDetails.WithLabelValues(greetconnect.GreetServiceName, "Greet", "failed_precondition", "google.protobuf.StringValue")))
+	}
+}
+
+func TestNewClientInterceptor(t *testing.T) {
+	DefaultServerMetrics.Reset()
+
+	clientMetrics := NewClientMetrics()
+	reporter := &recordingReporter{}
+	interceptor := NewClientInterceptor(clientMetrics, WithClientReporter(reporter), WithSlowThreshold(time.Hour))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+
+	greetSnapshot, ok := clientMetrics.Snapshot().Procedure(greetconnect.GreetServiceName, "Greet")
+	require.True(t, ok)
+	require.EqualValues(t, 1, greetSnapshot.Handled())
+	require.Len(t, reporter.handled, 1)
+
+	_, ok = DefaultServerMetrics.Snapshot().Procedure(greetconnect.GreetServiceName, "Greet")
+	require.False(t, ok, "server-side RPCs must not be reported to the default metrics")
+}
+
+func TestNewServerInterceptor(t *testing.T) {
+	DefaultClientMetrics.Reset()
+
+	serverMetrics := NewServerMetrics()
+	reporter := &recordingReporter{}
+	interceptor := NewServerInterceptor(serverMetrics, WithServerReporter(reporter), WithLoggedCodes(connect.CodeInternal))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+
+	greetSnapshot, ok := serverMetrics.Snapshot().Procedure(greetconnect.GreetServiceName, "Greet")
+	require.True(t, ok)
+	require.EqualValues(t, 1, greetSnapshot.Handled())
+	require.Len(t, reporter.handled, 1)
+
+	_, ok = DefaultClientMetrics.Snapshot().Procedure(greetconnect.GreetServiceName, "Greet")
+	require.False(t, ok, "client-side RPCs must not be reported to the default metrics")
+}
+
+func TestRPCCodeOf(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.Equal(t, "ok", rpcCodeOf(context.Background(), nil))
+	require.Equal(t, "canceled", rpcCodeOf(canceled, context.Canceled))
+	require.Equal(t, "deadline_exceeded", rpcCodeOf(newCanceledPastDeadline(), context.Canceled))
+	require.False(t, isClientCanceled(newCanceledPastDeadline(), connect.Spec{}, rpcCodeOf(newCanceledPastDeadline(), context.Canceled)))
+}
@@ -0,0 +1,147 @@
+package connect_go_prometheus
+
+// Label is a property of an RPC which metrics are labelled with.
+type Label int
+
+const (
+	LabelType Label = iota
+	LabelService
+	LabelMethod
+	LabelCode
+	// LabelCaller is the identity of the client, only set server-side with WithCallerLabel.
+	LabelCaller
+)
+
+// labels configures how the type, service, method and code of an RPC are labelled on each vector.
+type labels struct {
+	typeName    string
+	serviceName string
+	methodName  string
+	codeName    string
+	callerName  string
+
+	// formatType and formatCode convert type and code values, when set.
+	formatType func(string) string
+	formatCode func(string) string
+
+	// Labels each group of vectors is labelled with. Started is also used by message, compression, retry
+	// started and attempts vectors, handled is also used by the retry handled vector.
+	started      []Label
+	handled      []Label
+	histogram    []Label
+	errorDetails []Label
+}
+
+func defaultLabels() *labels {
+	return &labels{
+		typeName:     "type",
+		serviceName:  "service",
+		methodName:   "method",
+		codeName:     "code",
+		callerName:   "caller",
+		started:      []Label{LabelType, LabelService, LabelMethod},
+		handled:      []Label{LabelType, LabelService, LabelMethod, LabelCode},
+		histogram:    []Label{LabelType, LabelService, LabelMethod, LabelCode},
+		errorDetails: []Label{LabelService, LabelMethod, LabelCode},
+	}
+}
+
+func (l *labels) name(d Label) string {
+	switch d {
+	case LabelType:
+		return l.typeName
+	case LabelService:
+		return l.serviceName
+	case LabelMethod:
+		return l.methodName
+	case LabelCaller:
+		return l.callerName
+	default:
+		return l.codeName
+	}
+}
+
+// names returns the label names of a vector labelled with dims, followed by extra labels.
+func (l *labels) names(dims []Label, extra ...string) []string {
+	names := make([]string, 0, len(dims)+len(extra))
+	for _, d := range dims {
+		names = append(names, l.name(d))
+	}
+	return append(names, extra...)
+}
+
+// rpcLabels are the values of the labels of an RPC.
+type rpcLabels struct {
+	callType string
+	service  string
+	method   string
+	code     string
+	caller   string
+}
+
+// values returns the label values of a vector labelled with dims, followed by extra values.
+func (l *labels) values(dims []Label, rpc rpcLabels, extra ...string) []string {
+	values := make([]string, 0, len(dims)+len(extra))
+	for _, d := range dims {
+		switch d {
+		case LabelType:
+			callType := rpc.callType
+			if l.formatType != nil {
+				callType = l.formatType(callType)
+			}
+			values = append(values, callType)
+		case LabelService:
+			values = append(values, rpc.service)
+		case LabelMethod:
+			values = append(values, rpc.method)
+		case LabelCode:
+			code := rpc.code
+			if l.formatCode != nil {
+				code = l.formatCode(code)
+			}
+			values = append(values, code)
+		case LabelCaller:
+			values = append(values, rpc.caller)
+		}
+	}
+	return append(values, extra...)
+}
+
+// without returns the labels which are not dropped.
+func without(labels []Label, dropped []Label) []Label {
+	kept := make([]Label, 0, len(labels))
+	for _, l := range labels {
+		if !containsLabel(dropped, l) {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+func containsLabel(labels []Label, l Label) bool {
+	for _, candidate := range labels {
+		if candidate == l {
+			return true
+		}
+	}
+	return false
+}
+
+// WithoutLabels leaves the labels out of all vectors, to reduce cardinality. For example, dropping LabelMethod
+// rolls metrics up per service.
+func WithoutLabels(dropped ...Label) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.labels.started = without(opts.labels.started, dropped)
+		opts.labels.handled = without(opts.labels.handled, dropped)
+		opts.labels.histogram = without(opts.labels.histogram, dropped)
+		opts.labels.errorDetails = without(opts.labels.errorDetails, dropped)
+	}
+}
+
+// WithoutHistogramLabels leaves the labels out of the handled seconds histogram only, for example LabelCode to
+// keep the code on counters while reducing the number of histogram series.
+func WithoutHistogramLabels(dropped ...Label) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.labels.histogram = without(opts.labels.histogram, dropped)
+	}
+}
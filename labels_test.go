@@ -0,0 +1,76 @@
+package connect_go_prometheus
+
+import (
+	"strings"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_WithoutLabels(t *testing.T) {
+	metrics := NewServerMetrics(
+		WithHistogram(true),
+		WithHistogramBuckets([]float64{1}),
+		WithoutLabels(LabelType, LabelMethod),
+	)
+	reg := prom.NewRegistry()
+	reg.MustRegister(metrics)
+
+	metrics.ReportStarted("unary", "greet.v1.GreetService", "Greet")
+	metrics.ReportStarted("unary", "greet.v1.GreetService", "Farewell")
+	metrics.ReportHandled("unary", "greet.v1.GreetService", "Greet", "ok")
+	metrics.ReportHandled("unary", "greet.v1.GreetService", "Farewell", "ok")
+	metrics.ReportHandledSeconds("unary", "greet.v1.GreetService", "Greet", "ok", 0.5)
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP connect_server_handled_seconds Histogram of RPCs handled server-side
+		# TYPE connect_server_handled_seconds histogram
+		connect_server_handled_seconds_bucket{code="ok",service="greet.v1.GreetService",le="1"} 1
+		connect_server_handled_seconds_bucket{code="ok",service="greet.v1.GreetService",le="+Inf"} 1
+		connect_server_handled_seconds_sum{code="ok",service="greet.v1.GreetService"} 0.5
+		connect_server_handled_seconds_count{code="ok",service="greet.v1.GreetService"} 1
+		# HELP connect_server_handled_total Total number of RPCs handled server-side
+		# TYPE connect_server_handled_total counter
+		connect_server_handled_total{code="ok",service="greet.v1.GreetService"} 2
+		# HELP connect_server_started_total Total number of RPCs started handling server-side
+		# TYPE connect_server_started_total counter
+		connect_server_started_total{service="greet.v1.GreetService"} 2
+	`), "connect_server_started_total", "connect_server_handled_total", "connect_server_handled_seconds")
+	require.NoError(t, err)
+
+	snapshot, ok := metrics.Snapshot().Procedure("greet.v1.GreetService", "")
+	require.True(t, ok)
+	require.EqualValues(t, 2, snapshot.Started)
+	require.EqualValues(t, 2, snapshot.Handled())
+}
+
+func TestMetrics_WithoutHistogramLabels(t *testing.T) {
+	metrics := NewServerMetrics(
+		WithHistogram(true),
+		WithHistogramBuckets([]float64{1}),
+		WithoutHistogramLabels(LabelCode),
+	)
+	reg := prom.NewRegistry()
+	reg.MustRegister(metrics)
+
+	metrics.ReportHandled("unary", "greet.v1.GreetService", "Greet", "ok")
+	metrics.ReportHandled("unary", "greet.v1.GreetService", "Greet", "not_found")
+	metrics.ReportHandledSeconds("unary", "greet.v1.GreetService", "Greet", "ok", 0.5)
+	metrics.ReportHandledSeconds("unary", "greet.v1.GreetService", "Greet", "not_found", 0.5)
+
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP connect_server_handled_seconds Histogram of RPCs handled server-side
+		# TYPE connect_server_handled_seconds histogram
+		connect_server_handled_seconds_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="1"} 2
+		connect_server_handled_seconds_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="+Inf"} 2
+		connect_server_handled_seconds_sum{method="Greet",service="greet.v1.GreetService",type="unary"} 1
+		connect_server_handled_seconds_count{method="Greet",service="greet.v1.GreetService",type="unary"} 2
+		# HELP connect_server_handled_total Total number of RPCs handled server-side
+		# TYPE connect_server_handled_total counter
+		connect_server_handled_total{code="not_found",method="Greet",service="greet.v1.GreetService",type="unary"} 1
+		connect_server_handled_total{code="ok",method="Greet",service="greet.v1.GreetService",type="unary"} 1
+	`), "connect_server_handled_total", "connect_server_handled_seconds")
+	require.NoError(t, err)
+}
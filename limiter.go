@@ -0,0 +1,245 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+)
+
+const (
+	shedReasonLimit        = "limit"
+	shedReasonQueueTimeout = "queue_timeout"
+	shedReasonCanceled     = "canceled"
+)
+
+// NewLimiter creates a server-side interceptor which limits the number of RPCs handled concurrently. RPCs over the
+// limit are queued for up to the timeout configured with WithQueueTimeout, then rejected with resource_exhausted.
+// In-flight RPCs, shed RPCs and queue waits are reported to the server metrics configured with WithLimiterMetrics, by default
+// DefaultServerMetrics.
+//
+// Install the limiter after the Interceptor, so rejected RPCs are also counted as handled:
+//
+//	connect.WithInterceptors(connect_go_prometheus.NewInterceptor(), connect_go_prometheus.NewLimiter(connect_go_prometheus.WithLimit(100)))
+func NewLimiter(opts ...LimiterOption) *Limiter {
+	options := evaluateLimiterOptions(&limiterOptions{
+		metrics:         DefaultServerMetrics,
+		serviceLimits:   map[string]int{},
+		procedureLimits: map[string]int{},
+	}, opts...)
+
+	return &Limiter{
+		options: options,
+		slots:   map[string]chan struct{}{},
+	}
+}
+
+var _ connect.Interceptor = (*Limiter)(nil)
+
+// Limiter is a connect.Interceptor limiting concurrent server-side RPCs, see NewLimiter. Client-side RPCs are not
+// limited.
+type Limiter struct {
+	options *limiterOptions
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+func (l *Limiter) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return connect.UnaryFunc(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+
+		release, err := l.acquire(ctx, req.Spec())
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		return next(ctx, req)
+	})
+}
+
+func (l *Limiter) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (l *Limiter) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return connect.StreamingHandlerFunc(func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		release, err := l.acquire(ctx, conn.Spec())
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return next(ctx, conn)
+	})
+}
+
+// InFlight returns the number of RPCs of the procedure currently handled, counted against its limit. RPCs of a
+// service with a service-wide limit are counted together.
+func (l *Limiter) InFlight(procedure string) int {
+	key, _ := l.limitOf(procedure)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.slots[key])
+}
+
+// acquire takes a slot for the RPC, waiting for the queue timeout when all slots are taken.
+func (l *Limiter) acquire(ctx context.Context, spec connect.Spec) (func(), error) {
+	key, limit := l.limitOf(spec.Procedure)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	slots := l.slotsOf(key, limit)
+	acquired := func() func() {
+		l.inFlight(key, 1)
+		return func() {
+			<-slots
+			l.inFlight(key, -1)
+		}
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return acquired(), nil
+	default:
+	}
+
+	service, method := procedureToPackageAndMethod(spec.Procedure)
+	callType := steamTypeString(spec.StreamType)
+
+	if l.options.queueTimeout <= 0 {
+		l.shed(callType, service, method, shedReasonLimit)
+		return nil, errLimitReached()
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(l.options.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		l.queued(callType, service, method, time.Since(start))
+		return acquired(), nil
+	case <-timer.C:
+		l.queued(callType, service, method, time.Since(start))
+		l.shed(callType, service, method, shedReasonQueueTimeout)
+		return nil, errLimitReached()
+	case <-ctx.Done():
+		l.queued(callType, service, method, time.Since(start))
+		l.shed(callType, service, method, shedReasonCanceled)
+		return nil, contextErrorOf(ctx)
+	}
+}
+
+func errLimitReached() error {
+	return connect.NewError(connect.CodeResourceExhausted, errors.New("concurrency limit reached"))
+}
+
+// limitOf returns the key RPCs of the procedure are limited by, and its limit. Procedure limits take precedence over
+// service limits, which take precedence over the default per-method limit.
+func (l *Limiter) limitOf(procedure string) (string, int) {
+	if limit, ok := l.options.procedureLimits[procedure]; ok {
+		return procedure, limit
+	}
+
+	service, _ := procedureToPackageAndMethod(procedure)
+	if limit, ok := l.options.serviceLimits[service]; ok {
+		return service, limit
+	}
+
+	return procedure, l.options.limit
+}
+
+func (l *Limiter) slotsOf(key string, limit int) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots, ok := l.slots[key]
+	if !ok {
+		slots = make(chan struct{}, limit)
+		l.slots[key] = slots
+	}
+	return slots
+}
+
+func (l *Limiter) shed(callType, service, method, reason string) {
+	if l.options.metrics != nil {
+		l.options.metrics.ReportShed(callType, service, method, reason)
+	}
+}
+
+func (l *Limiter) inFlight(key string, delta int) {
+	if l.options.metrics != nil {
+		l.options.metrics.ReportLimiterInFlight(key, delta)
+	}
+}
+
+func (l *Limiter) queued(callType, service, method string, wait time.Duration) {
+	if l.options.metrics != nil {
+		l.options.metrics.ReportQueueWait(callType, service, method, wait.Seconds())
+	}
+}
+
+type limiterOptions struct {
+	metrics *Metrics
+
+	limit           int
+	serviceLimits   map[string]int
+	procedureLimits map[string]int
+
+	queueTimeout time.Duration
+}
+
+type LimiterOption func(*limiterOptions)
+
+// WithLimit limits the number of concurrent RPCs of each method. By default, RPCs are only limited by the limits
+// configured with WithServiceLimit and WithProcedureLimit.
+func WithLimit(limit int) LimiterOption {
+	return func(lo *limiterOptions) {
+		lo.limit = limit
+	}
+}
+
+// WithServiceLimit limits the number of concurrent RPCs across all methods of the service, for example
+// greet.v1.GreetService.
+func WithServiceLimit(service string, limit int) LimiterOption {
+	return func(lo *limiterOptions) {
+		lo.serviceLimits[service] = limit
+	}
+}
+
+// WithProcedureLimit limits the number of concurrent RPCs of the procedure, for example /greet.v1.GreetService/Greet.
+func WithProcedureLimit(procedure string, limit int) LimiterOption {
+	return func(lo *limiterOptions) {
+		lo.procedureLimits[procedure] = limit
+	}
+}
+
+// WithQueueTimeout queues RPCs over the limit for up to the timeout, instead of rejecting them immediately.
+func WithQueueTimeout(timeout time.Duration) LimiterOption {
+	return func(lo *limiterOptions) {
+		lo.queueTimeout = timeout
+	}
+}
+
+// WithLimiterMetrics reports in-flight RPCs, shed RPCs and queue waits to the server metrics. Use nil to disable reporting.
+func WithLimiterMetrics(m *Metrics) LimiterOption {
+	return func(lo *limiterOptions) {
+		lo.metrics = m
+	}
+}
+
+func evaluateLimiterOptions(defaults *limiterOptions, opts ...LimiterOption) *limiterOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
@@ -0,0 +1,129 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	for name, scenario := range map[string]struct {
+		opts   []LimiterOption
+		key    string
+		reason string
+		queued int
+	}{
+		"rejects over the limit": {
+			opts:   []LimiterOption{WithLimit(1)},
+			key:    "/greet.v1.GreetService/Greet",
+			reason: "limit",
+		},
+		"rejects once the queue times out": {
+			opts:   []LimiterOption{WithLimit(1), WithQueueTimeout(10 * time.Millisecond)},
+			key:    "/greet.v1.GreetService/Greet",
+			reason: "queue_timeout",
+			queued: 1,
+		},
+		"service limit": {
+			opts:   []LimiterOption{WithServiceLimit(greetconnect.GreetServiceName, 1)},
+			key:    greetconnect.GreetServiceName,
+			reason: "limit",
+		},
+		"procedure limit": {
+			opts:   []LimiterOption{WithLimit(10), WithProcedureLimit("/greet.v1.GreetService/Greet", 1)},
+			key:    "/greet.v1.GreetService/Greet",
+			reason: "limit",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			serverMetrics := NewServerMetrics()
+			interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(serverMetrics))
+			limiter := NewLimiter(append(scenario.opts, WithLimiterMetrics(serverMetrics))...)
+
+			server := blockingGreetServer{started: make(chan struct{}), release: make(chan struct{})}
+			_, handler := greetconnect.NewGreetServiceHandler(server, connect.WithInterceptors(interceptor, limiter))
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+
+			client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+			done := make(chan error)
+			go func() {
+				_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+				done <- err
+			}()
+			<-server.started
+			require.Equal(t, 1, limiter.InFlight("/greet.v1.GreetService/Greet"))
+			require.EqualValues(t, 1, testutil.ToFloat64(serverMetrics.limiterInFlight.WithLabelValues(scenario.key)))
+
+			_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+			require.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+
+			close(server.release)
+			require.NoError(t, <-done)
+			require.Equal(t, 0, limiter.InFlight("/greet.v1.GreetService/Greet"))
+			require.Zero(t, testutil.ToFloat64(serverMetrics.limiterInFlight.WithLabelValues(scenario.key)))
+
+			require.NoError(t, testutil.CollectAndCompare(serverMetrics, strings.NewReader(`
+				# HELP connect_server_handled_total Total number of RPCs handled server-side
+				# TYPE connect_server_handled_total counter
+				connect_server_handled_total{code="ok",method="Greet",service="greet.v1.GreetService",type="unary"} 1
+				connect_server_handled_total{code="resource_exhausted",method="Greet",service="greet.v1.GreetService",type="unary"} 1
+				# HELP connect_server_shed_total Total number of RPCs shed server-side by the Limiter
+				# TYPE connect_server_shed_total counter
+				connect_server_shed_total{method="Greet",reason="`+scenario.reason+`",service="greet.v1.GreetService",type="unary"} 1
+			`), "connect_server_handled_total", "connect_server_shed_total"))
+			require.Equal(t, scenario.queued, testutil.CollectAndCount(serverMetrics.queueWait))
+		})
+	}
+}
+
+func TestLimiter_Queue(t *testing.T) {
+	serverMetrics := NewServerMetrics()
+	limiter := NewLimiter(WithLimit(1), WithQueueTimeout(time.Minute), WithLimiterMetrics(serverMetrics))
+
+	server := blockingGreetServer{started: make(chan struct{}), release: make(chan struct{})}
+	_, handler := greetconnect.NewGreetServiceHandler(server, connect.WithInterceptors(limiter))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+			done <- err
+		}()
+	}
+
+	// The second RPC is queued until the first one completes.
+	<-server.started
+	require.Equal(t, 1, limiter.InFlight("/greet.v1.GreetService/Greet"))
+	server.release <- struct{}{}
+	<-server.started
+	server.release <- struct{}{}
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+
+	require.Zero(t, testutil.CollectAndCount(serverMetrics.shed))
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	limiter := NewLimiter(WithServiceLimit("other.v1.OtherService", 1))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(limiter))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(limiter))
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+}
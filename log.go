@@ -0,0 +1,70 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+)
+
+var (
+	defaultLoggedCodes = []connect.Code{
+		connect.CodeUnknown,
+		connect.CodeInternal,
+		connect.CodeUnavailable,
+		connect.CodeDataLoss,
+	}
+)
+
+var _ Reporter = (*logReporter)(nil)
+
+// logReporter emits a structured record for every RPC slower than the threshold, or ending with one of the codes.
+// Records use the same type, service, method and code vocabulary as the metrics.
+type logReporter struct {
+	logger    *slog.Logger
+	threshold time.Duration
+	codes     map[string]struct{}
+}
+
+func newLogReporter(logger *slog.Logger, threshold time.Duration, codes []connect.Code) *logReporter {
+	r := &logReporter{
+		logger:    logger,
+		threshold: threshold,
+		codes:     make(map[string]struct{}, len(codes)),
+	}
+	for _, code := range codes {
+		r.codes[code.String()] = struct{}{}
+	}
+	return r
+}
+
+func (r *logReporter) Started(context.Context, *Event) {}
+
+func (r *logReporter) Handled(ctx context.Context, e *Event) {
+	_, failed := r.codes[e.Code]
+	slow := r.threshold > 0 && e.Duration >= r.threshold
+	if !failed && !slow {
+		return
+	}
+
+	level, msg := slog.LevelWarn, "Slow RPC"
+	if failed {
+		level, msg = slog.LevelError, "RPC failed"
+	}
+
+	attrs := []slog.Attr{
+		slog.String("side", sideOf(e.Spec)),
+		slog.String("type", e.Type),
+		slog.String("service", e.Service),
+		slog.String("method", e.Method),
+		slog.String("code", e.Code),
+		slog.Duration("duration", e.Duration),
+		slog.String("peer", e.Peer.Addr),
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+
+	r.logger.LogAttrs(ctx, level, msg, attrs...)
+}
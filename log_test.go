@@ -0,0 +1,89 @@
+package connect_go_prometheus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptor_WithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(nil), WithLogger(logger))
+
+	_, handler := greetconnect.NewGreetServiceHandler(failingGreetServer{
+		err: connect.NewError(connect.CodeInternal, errors.New("database is down")),
+	}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	require.Equal(t, "ERROR", record["level"])
+	require.Equal(t, "RPC failed", record["msg"])
+	require.Equal(t, "server", record["side"])
+	require.Equal(t, "unary", record["type"])
+	require.Equal(t, greetconnect.GreetServiceName, record["service"])
+	require.Equal(t, "Greet", record["method"])
+	require.Equal(t, "internal", record["code"])
+	require.Equal(t, "internal: database is down", record["error"])
+	require.NotEmpty(t, record["peer"])
+	require.Contains(t, record, "duration")
+}
+
+func TestLogReporter(t *testing.T) {
+	for _, s := range []struct {
+		Name     string
+		Code     connect.Code
+		Duration time.Duration
+		Level    string
+	}{
+		{Name: "fast ok", Code: 0, Duration: time.Millisecond},
+		{Name: "slow ok", Code: 0, Duration: time.Second, Level: "WARN"},
+		{Name: "fast not found", Code: connect.CodeNotFound, Duration: time.Millisecond},
+		{Name: "fast unavailable", Code: connect.CodeUnavailable, Duration: time.Millisecond, Level: "ERROR"},
+	} {
+		t.Run(s.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			reporter := newLogReporter(slog.New(slog.NewJSONHandler(&buf, nil)), 100*time.Millisecond, defaultLoggedCodes)
+
+			e := newEvent(connect.Spec{Procedure: "/greet.v1.GreetService/Greet", IsClient: true}, connect.Peer{}, nil, nil)
+			e.Duration = s.Duration
+			if s.Code != 0 {
+				e.Err = connect.NewError(s.Code, errors.New("failed"))
+			}
+			e.Code = codeOf(e.Err)
+			reporter.Handled(context.Background(), e)
+
+			if s.Level == "" {
+				require.Empty(t, buf.String())
+				return
+			}
+
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+			require.Equal(t, s.Level, record["level"])
+			require.Equal(t, "client", record["side"])
+		})
+	}
+}
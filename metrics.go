@@ -1,9 +1,23 @@
 package connect_go_prometheus
 
 import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/bufbuild/connect-go"
 	prom "github.com/prometheus/client_golang/prometheus"
 )
 
+// codeClientCanceled is reported instead of canceled for RPCs canceled by the client, see WithClientCanceledCode.
+const codeClientCanceled = "client_canceled"
+
+var (
+	compressionRatioBuckets = prom.LinearBuckets(0.1, 0.1, 10)
+	attemptsBuckets         = []float64{1, 2, 3, 4, 5, 10}
+	queueWaitBuckets        = prom.ExponentialBuckets(0.001, 4, 8)
+)
+
 var (
 	DefaultClientMetrics = NewClientMetrics()
 	DefaultServerMetrics = NewServerMetrics()
@@ -17,110 +31,180 @@ func init() {
 
 // NewServerMetrics creates new Connect metrics for server-side handling.
 func NewServerMetrics(opts ...MetricsOption) *Metrics {
-	config := evaluateMetricsOptions(&metricsOptions{
-		histogramBuckets:          prom.DefBuckets,
-		requestStartedName:        "connect_server_started_total",
-		requestHandledName:        "connect_server_handled_total",
-		requestHandledSecondsName: "connect_server_handled_seconds",
-		streamMsgSentName:         "connect_server_msg_sent_total",
-		streamMsgReceivedName:     "connect_server_msg_received_total",
-	}, opts...)
+	return newMetrics(sideServer, opts...)
+}
 
-	m := &Metrics{
-		requestStarted: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestStartedName,
-			Help:        "Total number of RPCs started handling server-side",
-		}, []string{"type", "service", "method"}),
-		requestHandled: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestHandledName,
-			Help:        "Total number of RPCs handled server-side",
-		}, []string{"type", "service", "method", "code"}),
-		streamMsgSent: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.streamMsgSentName,
-			Help:        "Total number of stream messages sent by server-side",
-		}, []string{"type", "service", "method"}),
-		streamMsgReceived: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.streamMsgReceivedName,
-			Help:        "Total number of stream messages recieved by server-side",
-		}, []string{"type", "service", "method"}),
+func NewClientMetrics(opts ...MetricsOption) *Metrics {
+	return newMetrics(sideClient, opts...)
+}
+
+const (
+	sideServer = "server"
+	sideClient = "client"
+)
+
+func defaultMetricsOptions(side string) *metricsOptions {
+	prefix := "connect_" + side + "_"
+	opts := &metricsOptions{
+		side:                      side,
+		histogramBuckets:          prom.DefBuckets,
+		requestStartedName:        prefix + "started_total",
+		requestHandledName:        prefix + "handled_total",
+		requestHandledSecondsName: prefix + "handled_seconds",
+		streamMsgSentName:         prefix + "msg_sent_total",
+		streamMsgReceivedName:     prefix + "msg_received_total",
+		errorDetailsName:          prefix + "error_details_total",
+		compressionName:           prefix + "compression_total",
+		compressionRatioName:      prefix + "compression_ratio",
+		streamTerminatedName:      prefix + "stream_terminated_total",
+		labels:                    defaultLabels(),
+		now:                       time.Now,
 	}
 
-	if config.withHistogram {
-		m.requestHandledSeconds = prom.NewHistogramVec(prom.HistogramOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestHandledSecondsName,
-			Help:        "Histogram of RPCs handled server-side",
-			Buckets:     config.histogramBuckets,
-		}, []string{"type", "service", "method", "code"})
+	switch side {
+	case sideServer:
+		opts.rejectedName = prefix + "rejected_total"
+		opts.shedName = prefix + "shed_total"
+		opts.queueWaitName = prefix + "queue_wait_seconds"
+		opts.limiterInFlightName = prefix + "limiter_in_flight"
+	case sideClient:
+		opts.streamMsgReceivedName = prefix + "msg_recieved_total"
+		opts.retryStartedName = prefix + "retry_started_total"
+		opts.retryHandledName = prefix + "retry_handled_total"
+		opts.attemptsName = prefix + "attempts"
+		opts.transportDNSName = prefix + "dns_seconds"
+		opts.transportConnectName = prefix + "connect_seconds"
+		opts.transportTLSHandshakeName = prefix + "tls_handshake_seconds"
+		opts.transportFirstByteName = prefix + "first_byte_seconds"
+		opts.transportConnectionsName = prefix + "connections_total"
+		opts.transportRequestsName = prefix + "http_requests_total"
+		opts.healthErrorRateName = prefix + "endpoint_error_rate"
+		opts.healthLatencyName = prefix + "endpoint_latency_seconds"
+		opts.retryableCodes = []connect.Code{connect.CodeUnavailable}
 	}
 
-	return m
+	return opts
 }
 
-func NewClientMetrics(opts ...MetricsOption) *Metrics {
-	config := evaluateMetricsOptions(&metricsOptions{
-		histogramBuckets:          prom.DefBuckets,
-		requestStartedName:        "connect_client_started_total",
-		requestHandledName:        "connect_client_handled_total",
-		requestHandledSecondsName: "connect_client_handled_seconds",
-		streamMsgSentName:         "connect_client_msg_sent_total",
-		streamMsgReceivedName:     "connect_client_msg_recieved_total",
-	}, opts...)
+func newMetrics(side string, opts ...MetricsOption) *Metrics {
+	config := evaluateMetricsOptions(defaultMetricsOptions(side), opts...)
 
-	m := &Metrics{
-		requestStarted: prom.NewCounterVec(prom.CounterOpts{
+	counter := func(name, help string, labels []string) *prom.CounterVec {
+		return prom.NewCounterVec(prom.CounterOpts{
 			Namespace:   config.namespace,
 			Subsystem:   config.subsystem,
 			ConstLabels: config.constLabels,
-			Name:        config.requestStartedName,
-			Help:        "Total number of RPCs started handling client-side",
-		}, []string{"type", "service", "method"}),
-		requestHandled: prom.NewCounterVec(prom.CounterOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestHandledName,
-			Help:        "Total number of RPCs handled client-side",
-		}, []string{"type", "service", "method", "code"}),
-		streamMsgSent: prom.NewCounterVec(prom.CounterOpts{
+			Name:        name,
+			Help:        help,
+		}, labels)
+	}
+	gauge := func(name, help string, labels []string) *prom.GaugeVec {
+		return prom.NewGaugeVec(prom.GaugeOpts{
 			Namespace:   config.namespace,
 			Subsystem:   config.subsystem,
 			ConstLabels: config.constLabels,
-			Name:        config.streamMsgSentName,
-			Help:        "Total number of stream messages sent by client-side",
-		}, []string{"type", "service", "method"}),
-		streamMsgReceived: prom.NewCounterVec(prom.CounterOpts{
+			Name:        name,
+			Help:        help,
+		}, labels)
+	}
+	histogram := func(name, help string, buckets []float64, labels []string) *prom.HistogramVec {
+		return prom.NewHistogramVec(prom.HistogramOpts{
 			Namespace:   config.namespace,
 			Subsystem:   config.subsystem,
 			ConstLabels: config.constLabels,
-			Name:        config.streamMsgReceivedName,
-			Help:        "Total number of stream messages recieved by client-side",
-		}, []string{"type", "service", "method"}),
+			Name:        name,
+			Help:        help,
+			Buckets:     buckets,
+		}, labels)
+	}
+
+	labels := config.labels
+	m := &Metrics{
+		labels:       labels,
+		grpcMessages: config.grpcMessages,
+		requestStarted: counter(config.requestStartedName,
+			"Total number of RPCs started handling "+side+"-side",
+			labels.names(labels.started)),
+		requestHandled: counter(config.requestHandledName,
+			"Total number of RPCs handled "+side+"-side",
+			labels.names(labels.handled)),
+		streamMsgSent: counter(config.streamMsgSentName,
+			"Total number of stream messages sent by "+side+"-side",
+			labels.names(labels.started)),
+		streamMsgReceived: counter(config.streamMsgReceivedName,
+			"Total number of stream messages recieved by "+side+"-side",
+			labels.names(labels.started)),
+		errorDetails: counter(config.errorDetailsName,
+			"Total number of error details attached to RPCs handled "+side+"-side",
+			labels.names(labels.errorDetails, "detail_type")),
+		streamTerminated: counter(config.streamTerminatedName,
+			"Total number of streams terminated "+side+"-side by reason, and whether messages were exchanged",
+			labels.names(labels.started, "reason", "exchanged")),
 	}
 
 	if config.withHistogram {
-		m.requestHandledSeconds = prom.NewHistogramVec(prom.HistogramOpts{
-			Namespace:   config.namespace,
-			Subsystem:   config.subsystem,
-			ConstLabels: config.constLabels,
-			Name:        config.requestHandledSecondsName,
-			Help:        "Histogram of RPCs handled client-side",
-			Buckets:     config.histogramBuckets,
-		}, []string{"type", "service", "method", "code"})
+		m.requestHandledSeconds = histogram(config.requestHandledSecondsName,
+			"Histogram of RPCs handled "+side+"-side",
+			config.histogramBuckets,
+			labels.names(labels.histogram))
+	}
+
+	if config.withCompression {
+		body := "request"
+		if side == sideClient {
+			body = "response"
+		}
+		m.compression = counter(config.compressionName,
+			"Total number of RPCs handled "+side+"-side by compression encoding of the "+body,
+			labels.names(labels.started, "encoding"))
+		m.compressionRatio = histogram(config.compressionRatioName,
+			"Histogram of compressed to uncompressed size ratio of "+body+" bodies handled "+side+"-side",
+			compressionRatioBuckets,
+			labels.names(labels.started, "encoding"))
+	}
+
+	switch side {
+	case sideServer:
+		m.caller = config.caller
+		m.clientCanceledCode = config.clientCanceledCode
+		m.rejected = counter(config.rejectedName,
+			"Total number of requests rejected server-side before reaching the interceptor",
+			[]string{"reason", "http_status"})
+		m.shed = counter(config.shedName,
+			"Total number of RPCs shed server-side by the Limiter",
+			labels.names(labels.started, "reason"))
+		m.queueWait = histogram(config.queueWaitName,
+			"Histogram of time RPCs waited for a slot of the Limiter server-side",
+			queueWaitBuckets,
+			labels.names(labels.started))
+		m.limiterInFlight = gauge(config.limiterInFlightName,
+			"Number of RPCs in flight server-side per limit of the Limiter",
+			[]string{"key"})
+	case sideClient:
+		m.transport = newTransportMetrics(config)
+
+		if config.healthWindow > 0 {
+			m.health = newEndpointHealth(config)
+		}
+
+		if config.withRetryAttempts {
+			m.retryStarted = counter(config.retryStartedName,
+				"Total number of RPC retry attempts started client-side",
+				labels.names(labels.started))
+			m.retryHandled = counter(config.retryHandledName,
+				"Total number of RPC retry attempts handled client-side",
+				labels.names(labels.handled))
+			m.attempts = histogram(config.attemptsName,
+				"Histogram of attempts per RPC handled client-side",
+				attemptsBuckets,
+				labels.names(labels.started))
+			m.attemptHeader = config.attemptHeader
+			m.retryableCodes = config.retryableCodes
+		}
+	}
+
+	if config.seriesTTL > 0 {
+		m.expiry = newSeriesExpiry(config.seriesTTL, config.now)
 	}
 
 	return m
@@ -134,6 +218,29 @@ type Metrics struct {
 	requestHandledSeconds *prom.HistogramVec
 	streamMsgSent         *prom.CounterVec
 	streamMsgReceived     *prom.CounterVec
+	errorDetails          *prom.CounterVec
+	streamTerminated      *prom.CounterVec
+	rejected              *prom.CounterVec
+	shed                  *prom.CounterVec
+	queueWait             *prom.HistogramVec
+	limiterInFlight       *prom.GaugeVec
+	compression           *prom.CounterVec
+	compressionRatio      *prom.HistogramVec
+	retryStarted          *prom.CounterVec
+	retryHandled          *prom.CounterVec
+	attempts              *prom.HistogramVec
+	transport             *transportMetrics
+	health                *endpointHealth
+
+	labels *labels
+	caller *callerLabel
+	expiry *seriesExpiry
+
+	clientCanceledCode bool
+	grpcMessages       bool
+
+	attemptHeader  string
+	retryableCodes []connect.Code
 }
 
 // Describe implements Describe as required by prom.Collector
@@ -145,10 +252,35 @@ func (m *Metrics) Describe(c chan<- *prom.Desc) {
 	}
 	m.streamMsgSent.Describe(c)
 	m.streamMsgReceived.Describe(c)
+	m.errorDetails.Describe(c)
+	m.streamTerminated.Describe(c)
+	if m.rejected != nil {
+		m.rejected.Describe(c)
+		m.shed.Describe(c)
+		m.queueWait.Describe(c)
+		m.limiterInFlight.Describe(c)
+	}
+	if m.compression != nil {
+		m.compression.Describe(c)
+		m.compressionRatio.Describe(c)
+	}
+	if m.retryStarted != nil {
+		m.retryStarted.Describe(c)
+		m.retryHandled.Describe(c)
+		m.attempts.Describe(c)
+	}
+	if m.transport != nil {
+		m.transport.Describe(c)
+	}
+	if m.health != nil {
+		m.health.Describe(c)
+	}
 }
 
 // Collect implements collect as required by prom.Collector
 func (m *Metrics) Collect(c chan<- prom.Metric) {
+	m.expiry.expire()
+
 	m.requestStarted.Collect(c)
 	m.requestHandled.Collect(c)
 	if m.requestHandledSeconds != nil {
@@ -156,25 +288,241 @@ func (m *Metrics) Collect(c chan<- prom.Metric) {
 	}
 	m.streamMsgSent.Collect(c)
 	m.streamMsgReceived.Collect(c)
+	m.errorDetails.Collect(c)
+	m.streamTerminated.Collect(c)
+	if m.rejected != nil {
+		m.rejected.Collect(c)
+		m.shed.Collect(c)
+		m.queueWait.Collect(c)
+		m.limiterInFlight.Collect(c)
+	}
+	if m.compression != nil {
+		m.compression.Collect(c)
+		m.compressionRatio.Collect(c)
+	}
+	if m.retryStarted != nil {
+		m.retryStarted.Collect(c)
+		m.retryHandled.Collect(c)
+		m.attempts.Collect(c)
+	}
+	if m.transport != nil {
+		m.transport.Collect(c)
+	}
+	if m.health != nil {
+		m.health.Collect(c)
+	}
+}
+
+var _ Reporter = (*Metrics)(nil)
+
+// Started implements Started as required by Reporter
+func (m *Metrics) Started(ctx context.Context, e *Event) {
+	rpc := m.rpcLabelsOf(ctx, e)
+	m.reportStarted(rpc)
+	// Messages of streams are counted once handled, see Handled.
+	if e.Spec.StreamType == connect.StreamTypeUnary {
+		sent, received := m.unaryMessagesOf(e, false)
+		m.reportMessages(rpc, sent, received)
+	}
+	m.ReportRetryStarted(e.Type, e.Service, e.Method, attemptOf(ctx, e.RequestHeader, m.attemptHeader))
+}
+
+// Handled implements Handled as required by Reporter
+func (m *Metrics) Handled(ctx context.Context, e *Event) {
+	rpc := m.rpcLabelsOf(ctx, e)
+	m.reportHandled(rpc)
+	if e.Spec.StreamType == connect.StreamTypeUnary {
+		sent, received := m.unaryMessagesOf(e, true)
+		m.reportMessages(rpc, sent, received)
+	} else {
+		m.reportMessages(rpc, e.MessagesSent, e.MessagesReceived)
+	}
+	m.reportHandledSeconds(rpc, e.Duration.Seconds())
+	m.ReportRetryHandled(e.Type, e.Service, e.Method, e.Code, attemptOf(ctx, e.RequestHeader, m.attemptHeader), IsFinalAttempt(ctx))
+	for _, detailType := range errorDetailTypesOf(e.Err) {
+		m.reportErrorDetail(rpc, detailType)
+	}
+	if e.Reason != "" {
+		m.reportStreamTerminated(rpc, e.Reason, streamedMessagesOf(e) > 0)
+	}
+	if m.health != nil {
+		m.health.observe(e.Peer.Addr, e.Code, e.Duration)
+	}
+
+	if m.compression != nil {
+		if header, compressed, uncompressed := receivedBodyOf(ctx, e); header != nil {
+			encoding := compressionOf(header)
+			m.reportCompression(rpc, encoding)
+			if ratio, ok := compressionRatioOf(header, compressed, uncompressed); ok {
+				m.reportCompressionRatio(rpc, encoding, ratio)
+			}
+		}
+	}
+}
+
+// unaryMessagesOf returns the messages sent and received by this side of a unary RPC when it starts, or once it is
+// handled. One message is sent when it starts and one received once handled, unless messages are counted as
+// go-grpc-prometheus does: the request when the RPC starts, and the response once handled only when one was sent.
+func (m *Metrics) unaryMessagesOf(e *Event, handled bool) (sent, received int) {
+	if !m.grpcMessages {
+		if handled {
+			return 0, 1
+		}
+		return 1, 0
+	}
+
+	var requests, responses int
+	if !handled {
+		requests = 1
+	} else if e.Code == "ok" {
+		responses = 1
+	}
+	if e.Spec.IsClient {
+		return requests, responses
+	}
+	return responses, requests
 }
 
+func (m *Metrics) rpcLabelsOf(ctx context.Context, e *Event) rpcLabels {
+	rpc := rpcLabels{callType: e.Type, service: e.Service, method: e.Method, code: e.Code}
+	if m.clientCanceledCode && e.ClientCanceled {
+		rpc.code = codeClientCanceled
+	}
+	if m.caller != nil {
+		rpc.caller = m.caller.of(ctx, e.RequestHeader)
+	}
+	return rpc
+}
+
+// reportedRPC returns the labels of an RPC reported through the Report methods, which are not aware of the caller.
+func (m *Metrics) reportedRPC(callType, service, method, code string) rpcLabels {
+	rpc := rpcLabels{callType: callType, service: service, method: method, code: code}
+	if m.caller != nil {
+		rpc.caller = callerUnknown
+	}
+	return rpc
+}
+
+// ReportStarted records the start of an RPC, and counts one message sent as for unary RPCs.
 func (m *Metrics) ReportStarted(callType, service, method string) {
-	m.requestStarted.WithLabelValues(callType, service, method).Inc()
-	m.streamMsgSent.WithLabelValues(callType, service, method).Inc()
+	rpc := m.reportedRPC(callType, service, method, "")
+	m.reportStarted(rpc)
+	m.reportMessages(rpc, 1, 0)
+}
+
+func (m *Metrics) reportStarted(rpc rpcLabels) {
+	m.inc(m.requestStarted, m.labels.values(m.labels.started, rpc))
 }
 
+// ReportHandled records a handled RPC, and counts one message received as for unary RPCs.
 func (m *Metrics) ReportHandled(callType, service, method, code string) {
-	m.requestHandled.WithLabelValues(callType, service, method, code).Inc()
-	m.streamMsgReceived.WithLabelValues(callType, service, method).Inc()
+	rpc := m.reportedRPC(callType, service, method, code)
+	m.reportHandled(rpc)
+	m.reportMessages(rpc, 0, 1)
+}
+
+func (m *Metrics) reportHandled(rpc rpcLabels) {
+	m.inc(m.requestHandled, m.labels.values(m.labels.handled, rpc))
+}
+
+// ReportMessages records the messages sent and received by this side of a stream.
+func (m *Metrics) ReportMessages(callType, service, method string, sent, received int) {
+	m.reportMessages(m.reportedRPC(callType, service, method, ""), sent, received)
+}
+
+func (m *Metrics) reportMessages(rpc rpcLabels, sent, received int) {
+	values := m.labels.values(m.labels.started, rpc)
+	if sent > 0 {
+		m.add(m.streamMsgSent, values, float64(sent))
+	}
+	if received > 0 {
+		m.add(m.streamMsgReceived, values, float64(received))
+	}
 }
 
 func (m *Metrics) ReportHandledSeconds(callType, service, method, code string, val float64) {
+	m.reportHandledSeconds(m.reportedRPC(callType, service, method, code), val)
+}
+
+func (m *Metrics) reportHandledSeconds(rpc rpcLabels, val float64) {
 	if m.requestHandledSeconds != nil {
-		m.requestHandledSeconds.WithLabelValues(callType, service, method, code).Observe(val)
+		m.observe(m.requestHandledSeconds, m.labels.values(m.labels.histogram, rpc), val)
+	}
+}
+
+// ReportErrorDetail records an error detail, identified by its protobuf type name, attached to a failed RPC.
+func (m *Metrics) ReportErrorDetail(service, method, code, detailType string) {
+	m.reportErrorDetail(m.reportedRPC("", service, method, code), detailType)
+}
+
+func (m *Metrics) reportErrorDetail(rpc rpcLabels, detailType string) {
+	m.inc(m.errorDetails, m.labels.values(m.labels.errorDetails, rpc, detailType))
+}
+
+// ReportStreamTerminated records why a stream terminated, and whether any message was streamed before.
+func (m *Metrics) ReportStreamTerminated(callType, service, method, reason string, exchanged bool) {
+	m.reportStreamTerminated(m.reportedRPC(callType, service, method, ""), reason, exchanged)
+}
+
+func (m *Metrics) reportStreamTerminated(rpc rpcLabels, reason string, exchanged bool) {
+	m.inc(m.streamTerminated, m.labels.values(m.labels.started, rpc, reason, strconv.FormatBool(exchanged)))
+}
+
+// ReportRejected records a request rejected before reaching the interceptor, see WrapHandler.
+func (m *Metrics) ReportRejected(reason, httpStatus string) {
+	if m.rejected != nil {
+		m.rejected.WithLabelValues(reason, httpStatus).Inc()
+	}
+}
+
+// ReportShed records an RPC rejected by the Limiter, because the limit was reached, the queue timed out or the RPC
+// was canceled while queued.
+func (m *Metrics) ReportShed(callType, service, method, reason string) {
+	if m.shed != nil {
+		m.inc(m.shed, m.labels.values(m.labels.started, m.reportedRPC(callType, service, method, ""), reason))
+	}
+}
+
+// ReportLimiterInFlight adds delta to the RPCs in flight under a limit of the Limiter, identified by its key: the
+// procedure, or the service of a service-wide limit.
+func (m *Metrics) ReportLimiterInFlight(key string, delta int) {
+	if m.limiterInFlight != nil {
+		m.limiterInFlight.WithLabelValues(key).Add(float64(delta))
+	}
+}
+
+// ReportQueueWait records the time an RPC waited for a slot of the Limiter.
+func (m *Metrics) ReportQueueWait(callType, service, method string, val float64) {
+	if m.queueWait != nil {
+		m.observe(m.queueWait, m.labels.values(m.labels.started, m.reportedRPC(callType, service, method, "")), val)
+	}
+}
+
+// ReportCompression records the compression encoding of the received body, the request server-side and the response client-side.
+func (m *Metrics) ReportCompression(callType, service, method, encoding string) {
+	m.reportCompression(m.reportedRPC(callType, service, method, ""), encoding)
+}
+
+func (m *Metrics) reportCompression(rpc rpcLabels, encoding string) {
+	if m.compression != nil {
+		m.inc(m.compression, m.labels.values(m.labels.started, rpc, encoding))
+	}
+}
+
+// ReportCompressionRatio records the ratio of compressed to uncompressed size of the received body.
+func (m *Metrics) ReportCompressionRatio(callType, service, method, encoding string, val float64) {
+	m.reportCompressionRatio(m.reportedRPC(callType, service, method, ""), encoding, val)
+}
+
+func (m *Metrics) reportCompressionRatio(rpc rpcLabels, encoding string, val float64) {
+	if m.compressionRatio != nil {
+		m.observe(m.compressionRatio, m.labels.values(m.labels.started, rpc, encoding), val)
 	}
 }
 
 type metricsOptions struct {
+	side string
+
 	withHistogram    bool
 	histogramBuckets []float64
 
@@ -186,8 +534,46 @@ type metricsOptions struct {
 	requestHandledSecondsName string
 	streamMsgSentName         string
 	streamMsgReceivedName     string
+	errorDetailsName          string
+	streamTerminatedName      string
+	rejectedName              string
+	shedName                  string
+	queueWaitName             string
+	limiterInFlightName       string
+
+	withCompression      bool
+	compressionName      string
+	compressionRatioName string
+
+	withRetryAttempts bool
+	attemptHeader     string
+	retryableCodes    []connect.Code
+	retryStartedName  string
+	retryHandledName  string
+	attemptsName      string
+
+	transportDNSName          string
+	transportConnectName      string
+	transportTLSHandshakeName string
+	transportFirstByteName    string
+	transportConnectionsName  string
+	transportRequestsName     string
+
+	healthWindow        time.Duration
+	healthErrorRateName string
+	healthLatencyName   string
+
+	labels *labels
+	caller *callerLabel
+
+	clientCanceledCode bool
+	// grpcMessages counts the messages of unary RPCs as go-grpc-prometheus does, see WithGRPCNaming.
+	grpcMessages bool
 
 	constLabels prom.Labels
+
+	seriesTTL time.Duration
+	now       func() time.Time
 }
 
 type MetricsOption func(opts *metricsOptions)
@@ -204,6 +590,22 @@ func WithHistogramBuckets(buckets []float64) MetricsOption {
 	}
 }
 
+// WithCompression enables reporting of the compression encoding used for received bodies, and the ratio of
+// compressed to uncompressed body size.
+func WithCompression(enabled bool) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.withCompression = enabled
+	}
+}
+
+// WithClientCanceledCode reports server-side RPCs canceled by the client, because it canceled the RPC or
+// disconnected, with the client_canceled code. RPCs the handler ended with canceled on its own keep the canceled code.
+func WithClientCanceledCode(enabled bool) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.clientCanceledCode = enabled
+	}
+}
+
 func WithNamespace(namespace string) MetricsOption {
 	return func(opts *metricsOptions) {
 		opts.namespace = namespace
@@ -1,10 +1,16 @@
 package connect_go_prometheus
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
 	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
 	prom "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -100,3 +106,74 @@ func TestClientMetrics(t *testing.T) {
 		require.NoError(t, err)
 	}
 }
+
+// cancelingGreetServer blocks until the request context is done when started is set, otherwise it cancels the RPC
+// on its own.
+type cancelingGreetServer struct {
+	greetconnect.UnimplementedGreetServiceHandler
+	started chan struct{}
+}
+
+func (s cancelingGreetServer) Greet(ctx context.Context, _ *connect.Request[greet.GreetRequest]) (*connect.Response[greet.GreetResponse], error) {
+	if s.started == nil {
+		return nil, connect.NewError(connect.CodeCanceled, errors.New("downstream canceled"))
+	}
+
+	close(s.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestServerMetrics_WithClientCanceledCode(t *testing.T) {
+	for name, scenario := range map[string]struct {
+		opts     []MetricsOption
+		expected map[string]uint64
+	}{
+		"client_canceled": {
+			opts:     []MetricsOption{WithClientCanceledCode(true)},
+			expected: map[string]uint64{"canceled": 1, "client_canceled": 1},
+		},
+		"disabled": {
+			expected: map[string]uint64{"canceled": 2},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			serverMetrics := NewServerMetrics(scenario.opts...)
+			interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(serverMetrics))
+
+			server := cancelingGreetServer{started: make(chan struct{})}
+			_, handler := greetconnect.NewGreetServiceHandler(server, connect.WithInterceptors(interceptor))
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+
+			client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+
+			// The client cancels the RPC once the handler started.
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				<-server.started
+				cancel()
+			}()
+			_, err := client.Greet(ctx, connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+			require.Equal(t, connect.CodeCanceled, connect.CodeOf(err))
+
+			// The handler cancels the RPC on its own.
+			_, handler = greetconnect.NewGreetServiceHandler(cancelingGreetServer{}, connect.WithInterceptors(interceptor))
+			srv.Config.Handler = handler
+			_, err = client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+			require.Equal(t, connect.CodeCanceled, connect.CodeOf(err))
+
+			require.Eventually(t, func() bool {
+				greetSnapshot, ok := serverMetrics.Snapshot().Procedure(greetconnect.GreetServiceName, "Greet")
+				return ok && greetSnapshot.Handled() == 2
+			}, time.Second, 10*time.Millisecond)
+
+			greetSnapshot, _ := serverMetrics.Snapshot().Procedure(greetconnect.GreetServiceName, "Greet")
+			codes := map[string]uint64{}
+			for _, code := range greetSnapshot.Codes {
+				codes[code.Code] = code.Handled
+			}
+			require.Equal(t, scenario.expected, codes)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package connect_go_prometheus
+
+// The options below override the name of a metric. The name replaces the default, including its connect_server_ or
+// connect_client_ prefix, and is still prefixed by the namespace and subsystem when configured.
+
+// WithStartedName overrides the name of the started_total counter.
+func WithStartedName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.requestStartedName = name
+	}
+}
+
+// WithHandledName overrides the name of the handled_total counter.
+func WithHandledName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.requestHandledName = name
+	}
+}
+
+// WithHandledSecondsName overrides the name of the handled_seconds histogram.
+func WithHandledSecondsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.requestHandledSecondsName = name
+	}
+}
+
+// WithMsgSentName overrides the name of the msg_sent_total counter.
+func WithMsgSentName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.streamMsgSentName = name
+	}
+}
+
+// WithMsgReceivedName overrides the name of the msg_received_total counter, msg_recieved_total client-side.
+func WithMsgReceivedName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.streamMsgReceivedName = name
+	}
+}
+
+// WithErrorDetailsName overrides the name of the error_details_total counter.
+func WithErrorDetailsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.errorDetailsName = name
+	}
+}
+
+// WithStreamTerminatedName overrides the name of the stream_terminated_total counter.
+func WithStreamTerminatedName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.streamTerminatedName = name
+	}
+}
+
+// WithRejectedName overrides the name of the server-side rejected_total counter.
+func WithRejectedName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.rejectedName = name
+	}
+}
+
+// WithShedName overrides the name of the server-side shed_total counter.
+func WithShedName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.shedName = name
+	}
+}
+
+// WithQueueWaitName overrides the name of the server-side queue_wait_seconds histogram.
+func WithQueueWaitName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.queueWaitName = name
+	}
+}
+
+// WithLimiterInFlightName overrides the name of the server-side limiter_in_flight gauge.
+func WithLimiterInFlightName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.limiterInFlightName = name
+	}
+}
+
+// WithCompressionName overrides the name of the compression_total counter.
+func WithCompressionName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.compressionName = name
+	}
+}
+
+// WithCompressionRatioName overrides the name of the compression_ratio histogram.
+func WithCompressionRatioName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.compressionRatioName = name
+	}
+}
+
+// WithRetryStartedName overrides the name of the client-side retry_started_total counter.
+func WithRetryStartedName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.retryStartedName = name
+	}
+}
+
+// WithRetryHandledName overrides the name of the client-side retry_handled_total counter.
+func WithRetryHandledName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.retryHandledName = name
+	}
+}
+
+// WithAttemptsName overrides the name of the client-side attempts histogram.
+func WithAttemptsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.attemptsName = name
+	}
+}
+
+// WithDNSName overrides the name of the client-side dns_seconds histogram.
+func WithDNSName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.transportDNSName = name
+	}
+}
+
+// WithConnectName overrides the name of the client-side connect_seconds histogram.
+func WithConnectName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.transportConnectName = name
+	}
+}
+
+// WithTLSHandshakeName overrides the name of the client-side tls_handshake_seconds histogram.
+func WithTLSHandshakeName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.transportTLSHandshakeName = name
+	}
+}
+
+// WithFirstByteName overrides the name of the client-side first_byte_seconds histogram.
+func WithFirstByteName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.transportFirstByteName = name
+	}
+}
+
+// WithConnectionsName overrides the name of the client-side connections_total counter.
+func WithConnectionsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.transportConnectionsName = name
+	}
+}
+
+// WithHTTPRequestsName overrides the name of the client-side http_requests_total counter.
+func WithHTTPRequestsName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.transportRequestsName = name
+	}
+}
+
+// WithEndpointErrorRateName overrides the name of the client-side endpoint_error_rate gauge.
+func WithEndpointErrorRateName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.healthErrorRateName = name
+	}
+}
+
+// WithEndpointLatencyName overrides the name of the client-side endpoint_latency_seconds gauge.
+func WithEndpointLatencyName(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.healthLatencyName = name
+	}
+}
+
+// WithTypeLabel overrides the name of the label holding the stream type of an RPC, type by default.
+func WithTypeLabel(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.labels.typeName = name
+	}
+}
+
+// WithServiceLabel overrides the name of the label holding the service of an RPC, service by default.
+func WithServiceLabel(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.labels.serviceName = name
+	}
+}
+
+// WithMethodLabel overrides the name of the label holding the method of an RPC, method by default.
+func WithMethodLabel(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.labels.methodName = name
+	}
+}
+
+// WithCodeLabel overrides the name of the label holding the code an RPC was handled with, code by default.
+func WithCodeLabel(name string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.labels.codeName = name
+	}
+}
@@ -0,0 +1,70 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_CustomNames(t *testing.T) {
+	reg := prom.NewRegistry()
+	serverMetrics := NewServerMetrics(
+		WithHistogram(true),
+		WithHistogramBuckets([]float64{1}),
+		WithStartedName("rpc_server_started_total"),
+		WithHandledSecondsName("rpc_server_duration_seconds"),
+		WithServiceLabel("rpc_service"),
+		WithMethodLabel("rpc_method"),
+	)
+	reg.MustRegister(serverMetrics)
+
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(serverMetrics))
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP connect_server_handled_total Total number of RPCs handled server-side
+		# TYPE connect_server_handled_total counter
+		connect_server_handled_total{code="ok",rpc_method="Greet",rpc_service="greet.v1.GreetService",type="unary"} 1
+		# HELP rpc_server_started_total Total number of RPCs started handling server-side
+		# TYPE rpc_server_started_total counter
+		rpc_server_started_total{rpc_method="Greet",rpc_service="greet.v1.GreetService",type="unary"} 1
+	`), "rpc_server_started_total", "connect_server_handled_total")
+	require.NoError(t, err)
+
+	count, err := testutil.GatherAndCount(reg, "rpc_server_duration_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	greetSnapshot, ok := serverMetrics.Snapshot().Procedure(greetconnect.GreetServiceName, "Greet")
+	require.True(t, ok)
+	require.EqualValues(t, 1, greetSnapshot.Started)
+	require.EqualValues(t, 1, greetSnapshot.Handled())
+}
+
+func TestMetrics_CustomNamesWithNamespace(t *testing.T) {
+	metrics := NewClientMetrics(WithNamespace("acme"), WithHandledName("rpc_client_handled_total"))
+	metrics.ReportHandled("unary", "greet.v1.GreetService", "Greet", "ok")
+
+	reg := prom.NewRegistry()
+	reg.MustRegister(metrics)
+
+	count, err := testutil.GatherAndCount(reg, "acme_rpc_client_handled_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
@@ -0,0 +1,145 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	otelInstrumentationName = "github.com/easyCZ/connect-go-prometheus"
+
+	otelRPCSystem      = attribute.Key("rpc.system")
+	otelRPCService     = attribute.Key("rpc.service")
+	otelRPCMethod      = attribute.Key("rpc.method")
+	otelRPCConnectCode = attribute.Key("rpc.connect_rpc.error_code")
+)
+
+// NewOtelServerMetrics creates Connect metrics for server-side handling, recorded through an OpenTelemetry
+// MeterProvider following the RPC semantic conventions.
+func NewOtelServerMetrics(provider metric.MeterProvider) (*OtelMetrics, error) {
+	return newOtelMetrics(provider, "server", "inbound")
+}
+
+// NewOtelClientMetrics creates Connect metrics for client-side handling, recorded through an OpenTelemetry
+// MeterProvider following the RPC semantic conventions.
+func NewOtelClientMetrics(provider metric.MeterProvider) (*OtelMetrics, error) {
+	return newOtelMetrics(provider, "client", "outbound")
+}
+
+func newOtelMetrics(provider metric.MeterProvider, side, direction string) (*OtelMetrics, error) {
+	meter := provider.Meter(otelInstrumentationName)
+	prefix := "rpc." + side + "."
+
+	duration, err := meter.Float64Histogram(prefix+"duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("Measures the duration of "+direction+" RPC."))
+	if err != nil {
+		return nil, err
+	}
+	requestSize, err := meter.Int64Histogram(prefix+"request.size",
+		metric.WithUnit("By"),
+		metric.WithDescription("Measures the size of RPC request messages (uncompressed)."))
+	if err != nil {
+		return nil, err
+	}
+	responseSize, err := meter.Int64Histogram(prefix+"response.size",
+		metric.WithUnit("By"),
+		metric.WithDescription("Measures the size of RPC response messages (uncompressed)."))
+	if err != nil {
+		return nil, err
+	}
+	requestsPerRPC, err := meter.Int64Histogram(prefix+"requests_per_rpc",
+		metric.WithUnit("{count}"),
+		metric.WithDescription("Measures the number of request messages per RPC."))
+	if err != nil {
+		return nil, err
+	}
+	responsesPerRPC, err := meter.Int64Histogram(prefix+"responses_per_rpc",
+		metric.WithUnit("{count}"),
+		metric.WithDescription("Measures the number of response messages per RPC."))
+	if err != nil {
+		return nil, err
+	}
+
+	return &OtelMetrics{
+		duration:        duration,
+		requestSize:     requestSize,
+		responseSize:    responseSize,
+		requestsPerRPC:  requestsPerRPC,
+		responsesPerRPC: responsesPerRPC,
+	}, nil
+}
+
+// OtelMetrics is an OpenTelemetry alternative to Metrics. It can be used by the Interceptor instead of, or
+// together with Metrics, see WithClientOtelMetrics and WithServerOtelMetrics.
+type OtelMetrics struct {
+	duration        metric.Float64Histogram
+	requestSize     metric.Int64Histogram
+	responseSize    metric.Int64Histogram
+	requestsPerRPC  metric.Int64Histogram
+	responsesPerRPC metric.Int64Histogram
+}
+
+var _ Reporter = (*OtelMetrics)(nil)
+
+// Started implements Started as required by Reporter
+func (m *OtelMetrics) Started(context.Context, *Event) {}
+
+// Handled implements Handled as required by Reporter
+func (m *OtelMetrics) Handled(ctx context.Context, e *Event) {
+	if e.Spec.StreamType != connect.StreamTypeUnary {
+		requests, responses := messagesOf(e)
+		m.ReportStreamHandled(ctx, e.Service, e.Method, e.Code, e.Duration, requests, responses)
+		return
+	}
+	m.ReportHandled(ctx, e.Service, e.Method, e.Code, e.Duration, e.Request, e.Response)
+}
+
+// ReportHandled records a finished unary RPC. The code is only recorded for failed RPCs.
+func (m *OtelMetrics) ReportHandled(ctx context.Context, service, method, code string, duration time.Duration, request, response any) {
+	opt := otelAttributesOf(service, method, code)
+
+	m.duration.Record(ctx, float64(duration)/float64(time.Millisecond), opt)
+	m.requestSize.Record(ctx, int64(messageSizeOf(request)), opt)
+	m.requestsPerRPC.Record(ctx, 1, opt)
+
+	if response != nil {
+		m.responseSize.Record(ctx, int64(messageSizeOf(response)), opt)
+		m.responsesPerRPC.Record(ctx, 1, opt)
+	}
+}
+
+// ReportStreamHandled records a finished stream with the number of request and response messages exchanged. The
+// messages of streams are not retained, so their sizes are not recorded.
+func (m *OtelMetrics) ReportStreamHandled(ctx context.Context, service, method, code string, duration time.Duration, requests, responses int) {
+	opt := otelAttributesOf(service, method, code)
+
+	m.duration.Record(ctx, float64(duration)/float64(time.Millisecond), opt)
+	m.requestsPerRPC.Record(ctx, int64(requests), opt)
+	m.responsesPerRPC.Record(ctx, int64(responses), opt)
+}
+
+func otelAttributesOf(service, method, code string) metric.MeasurementOption {
+	attrs := []attribute.KeyValue{
+		otelRPCSystem.String("connect_rpc"),
+		otelRPCService.String(service),
+		otelRPCMethod.String(method),
+	}
+	if code != "ok" {
+		attrs = append(attrs, otelRPCConnectCode.String(code))
+	}
+	return metric.WithAttributes(attrs...)
+}
+
+// messageSizeOf returns the uncompressed size of a protobuf message, or 0 for other messages.
+func messageSizeOf(msg any) int {
+	if m, ok := msg.(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return 0
+}
@@ -0,0 +1,155 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestInterceptor_WithOtelMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	clientMetrics, err := NewOtelClientMetrics(provider)
+	require.NoError(t, err)
+	serverMetrics, err := NewOtelServerMetrics(provider)
+	require.NoError(t, err)
+
+	interceptor := NewInterceptor(
+		WithClientMetrics(nil),
+		WithServerMetrics(nil),
+		WithClientOtelMetrics(clientMetrics),
+		WithServerOtelMetrics(serverMetrics),
+	)
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	_, err = client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	histograms := map[string]metricdata.HistogramDataPoint[float64]{}
+	sizes := map[string]metricdata.HistogramDataPoint[int64]{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Histogram[float64]:
+			require.Len(t, data.DataPoints, 1)
+			histograms[m.Name] = data.DataPoints[0]
+		case metricdata.Histogram[int64]:
+			require.Len(t, data.DataPoints, 1)
+			sizes[m.Name] = data.DataPoints[0]
+		}
+	}
+
+	for _, name := range []string{"rpc.server.duration", "rpc.client.duration"} {
+		require.Contains(t, histograms, name)
+		require.EqualValues(t, 1, histograms[name].Count)
+
+		attrs := histograms[name].Attributes
+		system, _ := attrs.Value(otelRPCSystem)
+		require.Equal(t, "connect_rpc", system.AsString())
+		service, _ := attrs.Value(otelRPCService)
+		require.Equal(t, greetconnect.GreetServiceName, service.AsString())
+		method, _ := attrs.Value(otelRPCMethod)
+		require.Equal(t, "Greet", method.AsString())
+		require.False(t, attrs.HasValue(otelRPCConnectCode), "successful RPCs must not have an error code")
+	}
+
+	for _, name := range []string{
+		"rpc.server.request.size", "rpc.server.response.size", "rpc.server.requests_per_rpc", "rpc.server.responses_per_rpc",
+		"rpc.client.request.size", "rpc.client.response.size", "rpc.client.requests_per_rpc", "rpc.client.responses_per_rpc",
+	} {
+		require.Contains(t, sizes, name)
+		require.EqualValues(t, 1, sizes[name].Count)
+	}
+	require.EqualValues(t, 6, sizes["rpc.client.request.size"].Sum)
+}
+
+func TestInterceptor_WithOtelMetrics_ServerStream(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	clientMetrics, err := NewOtelClientMetrics(provider)
+	require.NoError(t, err)
+	serverMetrics, err := NewOtelServerMetrics(provider)
+	require.NoError(t, err)
+
+	interceptor := NewInterceptor(
+		WithClientMetrics(nil),
+		WithServerMetrics(nil),
+		WithClientOtelMetrics(clientMetrics),
+		WithServerOtelMetrics(serverMetrics),
+	)
+
+	_, handler := greetconnect.NewGreetServiceHandler(streamGreetServer{messages: 5}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	stream, err := client.ServerStreamGreet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+	for stream.Receive() {
+	}
+	require.NoError(t, stream.Err())
+	require.NoError(t, stream.Close())
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	counts := map[string]metricdata.HistogramDataPoint[int64]{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if data, ok := m.Data.(metricdata.Histogram[int64]); ok {
+			require.Len(t, data.DataPoints, 1)
+			counts[m.Name] = data.DataPoints[0]
+		}
+	}
+
+	for _, side := range []string{"server", "client"} {
+		require.EqualValues(t, 1, counts["rpc."+side+".requests_per_rpc"].Sum)
+		require.EqualValues(t, 5, counts["rpc."+side+".responses_per_rpc"].Sum)
+		require.NotContains(t, counts, "rpc."+side+".request.size", "stream messages are not retained")
+		require.NotContains(t, counts, "rpc."+side+".response.size", "stream messages are not retained")
+	}
+}
+
+func TestOtelMetrics_ErrorCode(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	serverMetrics, err := NewOtelServerMetrics(provider)
+	require.NoError(t, err)
+
+	serverMetrics.ReportHandled(context.Background(), greetconnect.GreetServiceName, "Greet", "not_found", 0, &greet.GreetRequest{}, nil)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	var found bool
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name != "rpc.server.duration" {
+			continue
+		}
+		found = true
+		point := m.Data.(metricdata.Histogram[float64]).DataPoints[0]
+		code, ok := point.Attributes.Value(attribute.Key("rpc.connect_rpc.error_code"))
+		require.True(t, ok)
+		require.Equal(t, "not_found", code.AsString())
+	}
+	require.True(t, found)
+}
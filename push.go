@@ -0,0 +1,146 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const defaultPushInterval = 15 * time.Second
+
+// NewPusher creates a Pusher which pushes metrics to the Prometheus Pushgateway at url, under the given job name.
+// It is meant for batch jobs and CLIs which exit before Prometheus can scrape them. By default, DefaultClientMetrics
+// are pushed every 15 seconds once started.
+func NewPusher(url, job string, opts ...PusherOption) *Pusher {
+	options := evaluatePusherOptions(&pusherOptions{
+		client:   DefaultClientMetrics,
+		interval: defaultPushInterval,
+	}, opts...)
+	if options.interval <= 0 {
+		options.interval = defaultPushInterval
+	}
+
+	pusher := push.New(url, job)
+	for _, m := range []*Metrics{options.client, options.server} {
+		if m != nil {
+			pusher = pusher.Collector(m)
+		}
+	}
+	for name, value := range options.grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	return &Pusher{
+		pusher:   pusher,
+		interval: options.interval,
+	}
+}
+
+// Pusher pushes connect metrics to a Prometheus Pushgateway, on an interval and on shutdown.
+type Pusher struct {
+	pusher   *push.Pusher
+	interval time.Duration
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Push pushes the metrics once, replacing all metrics previously pushed with the same job and grouping labels.
+func (p *Pusher) Push(ctx context.Context) error {
+	return p.pusher.PushContext(ctx)
+}
+
+// Start pushes the metrics on the configured interval, until Stop is called. Errors of interval pushes are ignored,
+// the next push retries with up to date metrics.
+func (p *Pusher) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.cancel, p.done = cancel, done
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = p.Push(ctx)
+			}
+		}
+	}()
+}
+
+// Stop stops pushing on the interval, and pushes the metrics a final time. Call it before your process exits.
+func (p *Pusher) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	return p.Push(ctx)
+}
+
+type pusherOptions struct {
+	client   *Metrics
+	server   *Metrics
+	interval time.Duration
+	grouping prom.Labels
+}
+
+type PusherOption func(*pusherOptions)
+
+// WithPushClientMetrics configures the client metrics to push, nil disables pushing client metrics.
+func WithPushClientMetrics(m *Metrics) PusherOption {
+	return func(opts *pusherOptions) {
+		opts.client = m
+	}
+}
+
+// WithPushServerMetrics configures the server metrics to push, by default server metrics are not pushed.
+func WithPushServerMetrics(m *Metrics) PusherOption {
+	return func(opts *pusherOptions) {
+		opts.server = m
+	}
+}
+
+// WithPushInterval configures how often metrics are pushed once the Pusher is started. Intervals which are not
+// positive fall back to the default of 15 seconds.
+func WithPushInterval(interval time.Duration) PusherOption {
+	return func(opts *pusherOptions) {
+		opts.interval = interval
+	}
+}
+
+// WithPushGrouping configures the grouping labels metrics are pushed with, in addition to the job.
+func WithPushGrouping(labels prom.Labels) PusherOption {
+	return func(opts *pusherOptions) {
+		opts.grouping = labels
+	}
+}
+
+func evaluatePusherOptions(defaults *pusherOptions, opts ...PusherOption) *pusherOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
@@ -0,0 +1,105 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+type pushgateway struct {
+	mu     sync.Mutex
+	paths  []string
+	bodies []string
+}
+
+func (g *pushgateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paths = append(g.paths, r.Method+" "+r.URL.Path)
+	g.bodies = append(g.bodies, string(body))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *pushgateway) pushes() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.paths)
+}
+
+func TestPusher_Push(t *testing.T) {
+	gateway := &pushgateway{}
+	srv := httptest.NewServer(gateway)
+	defer srv.Close()
+
+	clientMetrics := NewClientMetrics()
+	serverMetrics := NewServerMetrics()
+	clientMetrics.ReportStarted("unary", greetconnect.GreetServiceName, "Greet")
+	serverMetrics.ReportStarted("unary", greetconnect.GreetServiceName, "Greet")
+
+	pusher := NewPusher(srv.URL, "batch",
+		WithPushClientMetrics(clientMetrics),
+		WithPushServerMetrics(serverMetrics),
+		WithPushGrouping(prom.Labels{"instance": "cron-1"}),
+	)
+	require.NoError(t, pusher.Push(context.Background()))
+
+	require.Equal(t, []string{"PUT /metrics/job/batch/instance/cron-1"}, gateway.paths)
+	require.Contains(t, gateway.bodies[0], "connect_client_started_total")
+	require.Contains(t, gateway.bodies[0], "connect_server_started_total")
+}
+
+func TestPusher_StartStop(t *testing.T) {
+	gateway := &pushgateway{}
+	srv := httptest.NewServer(gateway)
+	defer srv.Close()
+
+	pusher := NewPusher(srv.URL, "batch",
+		WithPushClientMetrics(NewClientMetrics()),
+		WithPushInterval(10*time.Millisecond),
+	)
+	pusher.Start()
+
+	require.Eventually(t, func() bool {
+		return gateway.pushes() >= 2
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, pusher.Stop(context.Background()))
+	pushed := gateway.pushes()
+
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, pushed, gateway.pushes(), "must not push after stop")
+}
+
+func TestPusher_InvalidInterval(t *testing.T) {
+	srv := httptest.NewServer(&pushgateway{})
+	defer srv.Close()
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		pusher := NewPusher(srv.URL, "batch", WithPushClientMetrics(NewClientMetrics()), WithPushInterval(interval))
+		require.Equal(t, defaultPushInterval, pusher.interval)
+
+		pusher.Start()
+		require.NoError(t, pusher.Stop(context.Background()))
+	}
+}
+
+func TestPusher_Stop_ReportsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	pusher := NewPusher(srv.URL, "batch", WithPushClientMetrics(NewClientMetrics()))
+	require.Error(t, pusher.Stop(context.Background()))
+}
@@ -0,0 +1,104 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+)
+
+// Reporter receives an Event for every RPC observed by the Interceptor. Metrics and OtelMetrics are Reporters,
+// custom sinks can be added with WithClientReporter and WithServerReporter.
+type Reporter interface {
+	// Started is called before the RPC is handled.
+	Started(ctx context.Context, event *Event)
+	// Handled is called once the RPC is handled, with the same event passed to Started.
+	Handled(ctx context.Context, event *Event)
+}
+
+// Event describes an RPC observed by the Interceptor. Code, Err, Duration, ResponseHeader, Response and the stream
+// fields are only set once the RPC is handled. Reporters must not modify the event.
+type Event struct {
+	Spec connect.Spec
+	Peer connect.Peer
+
+	// Type, Service and Method are the labels the RPC is reported with.
+	Type    string
+	Service string
+	Method  string
+
+	// Code is the outcome of the RPC, ok for successful RPCs.
+	Code string
+	Err  error
+	// ClientCanceled is set server-side when the RPC ended with canceled because the client canceled it, rather
+	// than because the handler returned canceled on its own.
+	ClientCanceled bool
+
+	Start    time.Time
+	Duration time.Duration
+
+	RequestHeader  http.Header
+	ResponseHeader http.Header
+	// Request and Response are the messages of unary RPCs, they are not set for streams.
+	Request  any
+	Response any
+
+	// Reason is why a stream terminated: eof, client_canceled, server_error, deadline_exceeded or peer_gone. It is
+	// empty for unary RPCs.
+	Reason string
+	// MessagesSent and MessagesReceived count the messages exchanged on a stream by this side.
+	MessagesSent     int
+	MessagesReceived int
+}
+
+// RequestSize returns the uncompressed size of the request message, when it is a protobuf message.
+func (e *Event) RequestSize() int {
+	return messageSizeOf(e.Request)
+}
+
+// ResponseSize returns the uncompressed size of the response message, when it is a protobuf message.
+func (e *Event) ResponseSize() int {
+	return messageSizeOf(e.Response)
+}
+
+func newEvent(spec connect.Spec, peer connect.Peer, header http.Header, request any) *Event {
+	service, method := procedureToPackageAndMethod(spec.Procedure)
+	return &Event{
+		Spec:          spec,
+		Peer:          peer,
+		Type:          steamTypeString(spec.StreamType),
+		Service:       service,
+		Method:        method,
+		Start:         time.Now(),
+		RequestHeader: header,
+		Request:       request,
+	}
+}
+
+func (e *Event) handled(ctx context.Context, resp connect.AnyResponse, err error) {
+	e.Duration = time.Since(e.Start)
+	e.Code = rpcCodeOf(ctx, err)
+	e.Err = err
+	e.ClientCanceled = isClientCanceled(ctx, e.Spec, e.Code)
+	// Handlers may return a typed nil response together with an error.
+	if err == nil && resp != nil {
+		e.ResponseHeader = resp.Header()
+		e.Response = resp.Any()
+	}
+}
+
+func (e *Event) handledStream(ctx context.Context, responseHeader http.Header, err error) {
+	e.Duration = time.Since(e.Start)
+	e.Code = rpcCodeOf(ctx, err)
+	e.Err = err
+	e.ClientCanceled = isClientCanceled(ctx, e.Spec, e.Code)
+	e.ResponseHeader = responseHeader
+}
+
+// isClientCanceled reports whether a server-side RPC ended with canceled because its request context was canceled,
+// which happens when the client cancels the RPC or disconnects.
+func isClientCanceled(ctx context.Context, spec connect.Spec, code string) bool {
+	return !spec.IsClient && code == connect.CodeCanceled.String() && errors.Is(ctx.Err(), context.Canceled)
+}
@@ -0,0 +1,72 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingReporter struct {
+	mu      sync.Mutex
+	started []*Event
+	handled []*Event
+}
+
+func (r *recordingReporter) Started(_ context.Context, e *Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = append(r.started, e)
+}
+
+func (r *recordingReporter) Handled(_ context.Context, e *Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handled = append(r.handled, e)
+}
+
+func TestInterceptor_WithReporters(t *testing.T) {
+	client, server, other := &recordingReporter{}, &recordingReporter{}, &recordingReporter{}
+
+	interceptor := NewInterceptor(
+		WithClientMetrics(nil),
+		WithServerMetrics(nil),
+		WithClientReporter(client),
+		WithServerReporter(server),
+		WithServerReporter(other),
+	)
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	greetClient := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	_, err := greetClient.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+
+	for _, r := range []*recordingReporter{client, server, other} {
+		require.Len(t, r.started, 1)
+		require.Len(t, r.handled, 1)
+		require.Same(t, r.started[0], r.handled[0], "started and handled must receive the same event")
+
+		e := r.handled[0]
+		require.Equal(t, "unary", e.Type)
+		require.Equal(t, greetconnect.GreetServiceName, e.Service)
+		require.Equal(t, "Greet", e.Method)
+		require.Equal(t, "ok", e.Code)
+		require.NoError(t, e.Err)
+		require.NotEmpty(t, e.Peer.Addr)
+		require.Equal(t, 6, e.RequestSize())
+		require.Equal(t, 12, e.ResponseSize())
+	}
+
+	require.True(t, client.handled[0].Spec.IsClient)
+	require.False(t, server.handled[0].Spec.IsClient)
+	require.Same(t, server.handled[0], other.handled[0], "chained reporters must receive the same event")
+}
@@ -0,0 +1,97 @@
+package connect_go_prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// procedureVector is a vector labelled by procedure, with the labels it is labelled with.
+type procedureVector struct {
+	vec    *prom.MetricVec
+	labels []Label
+}
+
+// procedureVectors returns the vectors labelled by procedure, those which are enabled.
+func (m *Metrics) procedureVectors() []procedureVector {
+	var vectors []procedureVector
+	counter := func(vec *prom.CounterVec, labels []Label) {
+		if vec != nil {
+			vectors = append(vectors, procedureVector{vec: vec.MetricVec, labels: labels})
+		}
+	}
+	histogram := func(vec *prom.HistogramVec, labels []Label) {
+		if vec != nil {
+			vectors = append(vectors, procedureVector{vec: vec.MetricVec, labels: labels})
+		}
+	}
+
+	counter(m.requestStarted, m.labels.started)
+	counter(m.requestHandled, m.labels.handled)
+	histogram(m.requestHandledSeconds, m.labels.histogram)
+	counter(m.streamMsgSent, m.labels.started)
+	counter(m.streamMsgReceived, m.labels.started)
+	counter(m.errorDetails, m.labels.errorDetails)
+	counter(m.streamTerminated, m.labels.started)
+	counter(m.shed, m.labels.started)
+	histogram(m.queueWait, m.labels.started)
+	counter(m.compression, m.labels.started)
+	histogram(m.compressionRatio, m.labels.started)
+	counter(m.retryStarted, m.labels.started)
+	counter(m.retryHandled, m.labels.handled)
+	histogram(m.attempts, m.labels.started)
+	return vectors
+}
+
+// DeleteProcedure deletes the series of the procedure, for example /greet.v1.GreetService/Greet, from all vectors.
+// Use it when a service is removed at runtime. When the method label is dropped, see WithoutLabels, the series of
+// the whole service are deleted. It returns the number of series deleted. The limiter_in_flight gauge of the Limiter
+// is kept, as RPCs of the procedure may still be in flight, and returns to 0 once they are handled.
+func (m *Metrics) DeleteProcedure(procedure string) int {
+	service, method := procedureToPackageAndMethod(procedure)
+
+	deleted := 0
+	for _, v := range m.procedureVectors() {
+		match := prom.Labels{}
+		// Label values of a vector are in the order of its labels, see labels.values.
+		positions := map[int]string{}
+		for i, l := range v.labels {
+			switch l {
+			case LabelService:
+				match[m.labels.serviceName] = service
+				positions[i] = service
+			case LabelMethod:
+				match[m.labels.methodName] = method
+				positions[i] = method
+			}
+		}
+		if len(match) == 0 {
+			continue
+		}
+		deleted += v.vec.DeletePartialMatch(match)
+		m.expiry.forget(v.vec, positions)
+	}
+	return deleted
+}
+
+// Reset deletes all series, for example to start each test from clean DefaultServerMetrics and
+// DefaultClientMetrics. RPCs in flight under a limit of the Limiter are kept, as they are still being handled.
+func (m *Metrics) Reset() {
+	for _, v := range m.procedureVectors() {
+		v.vec.Reset()
+	}
+	m.expiry.reset()
+	if m.rejected != nil {
+		m.rejected.Reset()
+	}
+	if m.transport != nil {
+		m.transport.Reset()
+	}
+	if m.health != nil {
+		m.health.Reset()
+	}
+}
+
+// Unregister unregisters the metrics from the registerer, for example before registering a replacement. It reports
+// whether the metrics were registered.
+func (m *Metrics) Unregister(reg prom.Registerer) bool {
+	return reg.Unregister(m)
+}
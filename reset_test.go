@@ -0,0 +1,92 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_DeleteProcedure(t *testing.T) {
+	metrics := NewServerMetrics(WithHistogram(true), WithHistogramBuckets([]float64{1}))
+	for _, method := range []string{"Greet", "Farewell"} {
+		metrics.ReportStarted("unary", "greet.v1.GreetService", method)
+		metrics.ReportHandled("unary", "greet.v1.GreetService", method, "ok")
+		metrics.ReportHandledSeconds("unary", "greet.v1.GreetService", method, "ok", 0.5)
+		metrics.ReportErrorDetail("greet.v1.GreetService", method, "ok", "google.rpc.RetryInfo")
+	}
+	metrics.ReportRejected("unknown_procedure", "404")
+
+	// started, handled, handled seconds, msg sent, msg received and error details.
+	require.Equal(t, 6, metrics.DeleteProcedure("/greet.v1.GreetService/Greet"))
+	require.Zero(t, metrics.DeleteProcedure("/greet.v1.GreetService/Greet"))
+
+	_, ok := metrics.Snapshot().Procedure("greet.v1.GreetService", "Greet")
+	require.False(t, ok)
+	_, ok = metrics.Snapshot().Procedure("greet.v1.GreetService", "Farewell")
+	require.True(t, ok)
+	require.Equal(t, 1, testutil.CollectAndCount(metrics.rejected))
+}
+
+func TestMetrics_DeleteProcedureForgetsExpiry(t *testing.T) {
+	metrics := NewServerMetrics(WithSeriesTTL(time.Minute))
+	for _, method := range []string{"Greet", "Farewell"} {
+		metrics.ReportStarted("unary", "greet.v1.GreetService", method)
+	}
+
+	metrics.DeleteProcedure("/greet.v1.GreetService/Greet")
+
+	// started and msg sent of Farewell.
+	tracked := 0
+	for _, entries := range metrics.expiry.series {
+		for _, entry := range entries {
+			require.Equal(t, "Farewell", entry.values[2])
+			tracked++
+		}
+	}
+	require.Equal(t, 2, tracked)
+}
+
+func TestMetrics_DeleteProcedureWithoutMethod(t *testing.T) {
+	metrics := NewServerMetrics(WithoutLabels(LabelMethod))
+	metrics.ReportStarted("unary", "greet.v1.GreetService", "Greet")
+	metrics.ReportStarted("unary", "other.v1.OtherService", "Greet")
+
+	// started and msg sent of the whole service.
+	require.Equal(t, 2, metrics.DeleteProcedure("/greet.v1.GreetService/Greet"))
+	require.NoError(t, testutil.CollectAndCompare(metrics.requestStarted, strings.NewReader(`
+		# HELP connect_server_started_total Total number of RPCs started handling server-side
+		# TYPE connect_server_started_total counter
+		connect_server_started_total{service="other.v1.OtherService",type="unary"} 1
+	`)))
+}
+
+func TestMetrics_Reset(t *testing.T) {
+	metrics := NewClientMetrics(WithRetryAttempts(true), WithEndpointHealth(time.Minute))
+	metrics.ReportStarted("unary", "greet.v1.GreetService", "Greet")
+	metrics.ReportHandled("unary", "greet.v1.GreetService", "Greet", "ok")
+	metrics.ReportRetryHandled("unary", "greet.v1.GreetService", "Greet", "ok", 2, false)
+	metrics.Handled(context.Background(), handledEvent("a.example.com", "ok", 0))
+
+	metrics.Reset()
+
+	require.Zero(t, testutil.CollectAndCount(metrics))
+	require.Empty(t, metrics.Endpoints())
+	require.Empty(t, metrics.Snapshot().Procedures)
+}
+
+func TestMetrics_Unregister(t *testing.T) {
+	reg := prom.NewRegistry()
+	metrics := NewServerMetrics()
+	reg.MustRegister(metrics)
+
+	require.True(t, metrics.Unregister(reg))
+	require.False(t, metrics.Unregister(reg))
+
+	// A replacement can be registered once unregistered.
+	require.NoError(t, reg.Register(NewServerMetrics()))
+}
@@ -0,0 +1,112 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/bufbuild/connect-go"
+)
+
+type (
+	attemptKey      struct{}
+	finalAttemptKey struct{}
+)
+
+// WithAttempt returns a context carrying the attempt number of a client RPC, starting at 1 for the first try.
+// Retry interceptors set it for every attempt they make, so client metrics can tell retries apart from
+// independent calls.
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// AttemptFromContext returns the attempt number set with WithAttempt, or 0 when it is not set.
+func AttemptFromContext(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
+}
+
+// WithFinalAttempt returns a context marking the attempt as the last one of the call, because the retry interceptor
+// gives up after it whatever its code. The attempts of calls which run out of retries are then observed too.
+func WithFinalAttempt(ctx context.Context) context.Context {
+	return context.WithValue(ctx, finalAttemptKey{}, true)
+}
+
+// IsFinalAttempt reports whether the context was marked with WithFinalAttempt.
+func IsFinalAttempt(ctx context.Context) bool {
+	final, _ := ctx.Value(finalAttemptKey{}).(bool)
+	return final
+}
+
+// attemptOf returns the attempt number of a client RPC from the context, falling back to the configured header.
+// 0 is returned when the attempt is not known.
+func attemptOf(ctx context.Context, header http.Header, attemptHeader string) int {
+	if attempt := AttemptFromContext(ctx); attempt > 0 {
+		return attempt
+	}
+
+	if attemptHeader == "" || header == nil {
+		return 0
+	}
+
+	attempt, err := strconv.Atoi(header.Get(attemptHeader))
+	if err != nil || attempt < 1 {
+		return 0
+	}
+	return attempt
+}
+
+// ReportRetryStarted records the start of an attempt. Only retries, attempts after the first, are counted.
+func (m *Metrics) ReportRetryStarted(callType, service, method string, attempt int) {
+	if m.retryStarted != nil && attempt > 1 {
+		m.inc(m.retryStarted, m.labels.values(m.labels.started, rpcLabels{callType: callType, service: service, method: method}))
+	}
+}
+
+// ReportRetryHandled records the outcome of an attempt. Only retries are counted. Attempts which did not fail
+// with a retryable code, or are final, finish the call, their attempt number is observed in the attempts histogram.
+func (m *Metrics) ReportRetryHandled(callType, service, method, code string, attempt int, final bool) {
+	if m.retryHandled == nil || attempt < 1 {
+		return
+	}
+
+	if attempt > 1 {
+		m.inc(m.retryHandled, m.labels.values(m.labels.handled, rpcLabels{callType: callType, service: service, method: method, code: code}))
+	}
+
+	if final || !m.isRetryable(code) {
+		m.observe(m.attempts, m.labels.values(m.labels.started, rpcLabels{callType: callType, service: service, method: method}), float64(attempt))
+	}
+}
+
+func (m *Metrics) isRetryable(code string) bool {
+	for _, retryable := range m.retryableCodes {
+		if retryable.String() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRetryAttempts enables reporting of client retry attempts. The attempt number is read from the context, see
+// WithAttempt, or from the header configured with WithAttemptHeader. Only applies to client metrics.
+func WithRetryAttempts(enabled bool) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.withRetryAttempts = enabled
+	}
+}
+
+// WithAttemptHeader configures the request header carrying the attempt number, used when the context has none.
+func WithAttemptHeader(header string) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.attemptHeader = header
+	}
+}
+
+// WithRetryableCodes configures the codes your retry policy retries, by default only connect.CodeUnavailable.
+// An attempt failing with any other code is the last attempt of the call.
+func WithRetryableCodes(codes ...connect.Code) MetricsOption {
+	return func(opts *metricsOptions) {
+		opts.retryableCodes = codes
+	}
+}
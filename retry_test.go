@@ -0,0 +1,132 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type flakyGreetServer struct {
+	greetconnect.UnimplementedGreetServiceHandler
+	failures int
+}
+
+func (s *flakyGreetServer) Greet(context.Context, *connect.Request[greet.GreetRequest]) (*connect.Response[greet.GreetResponse], error) {
+	if s.failures > 0 {
+		s.failures--
+		return nil, connect.NewError(connect.CodeUnavailable, errors.New("try again"))
+	}
+	return connect.NewResponse(&greet.GreetResponse{}), nil
+}
+
+// retryInterceptor retries unary calls failing with connect.CodeUnavailable, up to maxAttempts, marking the last.
+func retryInterceptor(maxAttempts int) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			var (
+				resp connect.AnyResponse
+				err  error
+			)
+			for attempt := 1; attempt <= maxAttempts; attempt++ {
+				attemptCtx := WithAttempt(ctx, attempt)
+				if attempt == maxAttempts {
+					attemptCtx = WithFinalAttempt(attemptCtx)
+				}
+				resp, err = next(attemptCtx, req)
+				if connect.CodeOf(err) != connect.CodeUnavailable {
+					break
+				}
+			}
+			return resp, err
+		}
+	}
+}
+
+func TestAttemptOf(t *testing.T) {
+	require.Equal(t, 0, attemptOf(context.Background(), nil, ""))
+	require.Equal(t, 2, attemptOf(WithAttempt(context.Background(), 2), nil, ""))
+	require.Equal(t, 3, attemptOf(context.Background(), http.Header{"X-Attempt": []string{"3"}}, "X-Attempt"))
+	require.Equal(t, 0, attemptOf(context.Background(), http.Header{"X-Attempt": []string{"nope"}}, "X-Attempt"))
+	require.Equal(t, 2, attemptOf(WithAttempt(context.Background(), 2), http.Header{"X-Attempt": []string{"3"}}, "X-Attempt"), "context takes precedence")
+}
+
+func TestInterceptor_WithRetryAttempts(t *testing.T) {
+	reg := prom.NewRegistry()
+	clientMetrics := NewClientMetrics(WithRetryAttempts(true))
+	reg.MustRegister(clientMetrics)
+
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(nil))
+
+	_, handler := greetconnect.NewGreetServiceHandler(&flakyGreetServer{failures: 2})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(retryInterceptor(5), interceptor))
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{
+		Name: "elza",
+	}))
+	require.NoError(t, err)
+
+	require.EqualValues(t, 3, testutil.ToFloat64(clientMetrics.requestStarted.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+	require.EqualValues(t, 2, testutil.ToFloat64(clientMetrics.retryStarted.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.retryHandled.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "unavailable")))
+	require.EqualValues(t, 1, testutil.ToFloat64(clientMetrics.retryHandled.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "ok")))
+
+	err = testutil.CollectAndCompare(clientMetrics.attempts, strings.NewReader(`
+		# HELP connect_client_attempts Histogram of attempts per RPC handled client-side
+		# TYPE connect_client_attempts histogram
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="1"} 0
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="2"} 0
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="3"} 1
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="4"} 1
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="5"} 1
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="10"} 1
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="+Inf"} 1
+		connect_client_attempts_sum{method="Greet",service="greet.v1.GreetService",type="unary"} 3
+		connect_client_attempts_count{method="Greet",service="greet.v1.GreetService",type="unary"} 1
+	`))
+	require.NoError(t, err)
+}
+
+func TestInterceptor_WithRetryAttempts_Exhausted(t *testing.T) {
+	clientMetrics := NewClientMetrics(WithRetryAttempts(true))
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(nil))
+
+	_, handler := greetconnect.NewGreetServiceHandler(&flakyGreetServer{failures: 5})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(retryInterceptor(3), interceptor))
+	_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{
+		Name: "elza",
+	}))
+	require.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+
+	require.EqualValues(t, 2, testutil.ToFloat64(clientMetrics.retryStarted.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+	require.EqualValues(t, 2, testutil.ToFloat64(clientMetrics.retryHandled.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "unavailable")))
+
+	err = testutil.CollectAndCompare(clientMetrics.attempts, strings.NewReader(`
+		# HELP connect_client_attempts Histogram of attempts per RPC handled client-side
+		# TYPE connect_client_attempts histogram
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="1"} 0
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="2"} 0
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="3"} 1
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="4"} 1
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="5"} 1
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="10"} 1
+		connect_client_attempts_bucket{method="Greet",service="greet.v1.GreetService",type="unary",le="+Inf"} 1
+		connect_client_attempts_sum{method="Greet",service="greet.v1.GreetService",type="unary"} 3
+		connect_client_attempts_count{method="Greet",service="greet.v1.GreetService",type="unary"} 1
+	`))
+	require.NoError(t, err)
+}
@@ -0,0 +1,50 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/bufbuild/connect-go"
+)
+
+// MetricsRouter picks the Metrics an RPC is reported to, from its spec and request headers. Returning nil skips
+// reporting the RPC.
+type MetricsRouter func(spec connect.Spec, header http.Header) *Metrics
+
+var _ Reporter = (*routingReporter)(nil)
+
+// routingReporter reports each RPC to the Metrics picked by the router. The pick is remembered between Started and
+// Handled, so both are reported to the same Metrics.
+type routingReporter struct {
+	router MetricsRouter
+	picked sync.Map // *Event -> *Metrics
+}
+
+func (r *routingReporter) Started(ctx context.Context, e *Event) {
+	m := r.router(e.Spec, e.RequestHeader)
+	if m == nil {
+		return
+	}
+
+	r.picked.Store(e, m)
+	m.Started(ctx, e)
+}
+
+func (r *routingReporter) Handled(ctx context.Context, e *Event) {
+	m, ok := r.picked.LoadAndDelete(e)
+	if !ok {
+		return
+	}
+
+	m.(*Metrics).Handled(ctx, e)
+}
+
+// WithServerMetricsRouter reports each server-side RPC to the Metrics picked by the router, instead of the Metrics
+// configured with WithServerMetrics. Use it to report the RPCs of different products or tenants to separate
+// registries. WrapHandler routes rejected requests the same way, with the procedure taken from the URL path.
+func WithServerMetricsRouter(router MetricsRouter) ServerInterceptorOption {
+	return serverInterceptorOption(func(io *interceptorOptions) {
+		io.serverRouter = router
+	})
+}
@@ -0,0 +1,65 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptor_WithServerMetricsRouter(t *testing.T) {
+	tenantA, tenantB := NewServerMetrics(), NewServerMetrics()
+	router := func(spec connect.Spec, header http.Header) *Metrics {
+		switch header.Get("X-Tenant") {
+		case "a":
+			return tenantA
+		case "b":
+			return tenantB
+		default:
+			return nil
+		}
+	}
+
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetricsRouter(router))
+
+	mux := http.NewServeMux()
+	mux.Handle(greetconnect.NewGreetServiceHandler(greetServer{}, connect.WithInterceptors(interceptor)))
+	srv := httptest.NewServer(WrapHandler(mux, WithServerMetricsRouter(router)))
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	greetAs := func(tenant string) {
+		req := connect.NewRequest(&greet.GreetRequest{Name: "elza"})
+		req.Header().Set("X-Tenant", tenant)
+		_, err := client.Greet(context.Background(), req)
+		require.NoError(t, err)
+	}
+
+	greetAs("a")
+	greetAs("a")
+	greetAs("b")
+	greetAs("unknown")
+
+	require.EqualValues(t, 2, testutil.ToFloat64(tenantA.requestStarted.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+	require.EqualValues(t, 2, testutil.ToFloat64(tenantA.requestHandled.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "ok")))
+	require.EqualValues(t, 1, testutil.ToFloat64(tenantB.requestStarted.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet")))
+	require.EqualValues(t, 1, testutil.ToFloat64(tenantB.requestHandled.WithLabelValues("unary", greetconnect.GreetServiceName, "Greet", "ok")))
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/unknown.v1.Service/Method", strings.NewReader("{}"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant", "b")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	require.EqualValues(t, 1, testutil.ToFloat64(tenantB.rejected.WithLabelValues("unknown_procedure", "404")))
+	require.Equal(t, 0, testutil.CollectAndCount(tenantA.rejected))
+}
@@ -0,0 +1,260 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+)
+
+// NewRPCz creates a debug page of in-flight RPCs and, per method, of recently completed, errored and slowest RPCs.
+// Add it as a reporter to the Interceptor, and mount it on your debug mux:
+//
+//	rpcz := connect_go_prometheus.NewRPCz()
+//	interceptor := connect_go_prometheus.NewInterceptor(connect_go_prometheus.WithServerReporter(rpcz))
+//	debugMux.Handle("/debug/rpcz", rpcz)
+func NewRPCz(opts ...RPCzOption) *RPCz {
+	options := evaluateRPCzOptions(&rpczOptions{
+		size: 10,
+	}, opts...)
+	if options.size < 1 {
+		options.size = 1
+	}
+
+	return &RPCz{
+		size:     options.size,
+		inFlight: map[*Event]RPCzEntry{},
+		methods:  map[string]*rpczMethod{},
+	}
+}
+
+var (
+	_ Reporter     = (*RPCz)(nil)
+	_ http.Handler = (*RPCz)(nil)
+)
+
+// RPCz records RPCs reported by the Interceptor, and serves them as an HTML page, or as JSON when requested with
+// ?format=json or an Accept: application/json header.
+type RPCz struct {
+	size int
+
+	mu sync.Mutex
+	// inFlight holds an entry per in-flight RPC, taken when it started, as the Event is written when it is handled.
+	inFlight map[*Event]RPCzEntry
+	methods  map[string]*rpczMethod
+}
+
+// RPCzEntry describes a single RPC on the debug page.
+type RPCzEntry struct {
+	Procedure string        `json:"procedure"`
+	Side      string        `json:"side"`
+	Peer      string        `json:"peer"`
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration"`
+	Code      string        `json:"code,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// RPCzMethod are the recent RPCs of a method, most recent first, and the slowest RPCs, slowest first.
+type RPCzMethod struct {
+	Procedure string      `json:"procedure"`
+	Completed []RPCzEntry `json:"completed"`
+	Errored   []RPCzEntry `json:"errored"`
+	Slowest   []RPCzEntry `json:"slowest"`
+}
+
+// RPCzPage is the content of the debug page.
+type RPCzPage struct {
+	InFlight []RPCzEntry  `json:"in_flight"`
+	Methods  []RPCzMethod `json:"methods"`
+}
+
+// Started implements Started as required by Reporter
+func (r *RPCz) Started(_ context.Context, e *Event) {
+	entry := RPCzEntry{
+		Procedure: e.Spec.Procedure,
+		Side:      sideOf(e.Spec),
+		Peer:      e.Peer.Addr,
+		Start:     e.Start,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inFlight[e] = entry
+}
+
+// Handled implements Handled as required by Reporter
+func (r *RPCz) Handled(_ context.Context, e *Event) {
+	entry := newRPCzEntry(e, e.Duration)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.inFlight, e)
+
+	m, ok := r.methods[entry.Procedure]
+	if !ok {
+		m = &rpczMethod{}
+		r.methods[entry.Procedure] = m
+	}
+
+	if e.Err != nil {
+		m.errored = pushRecent(m.errored, entry, r.size)
+	} else {
+		m.completed = pushRecent(m.completed, entry, r.size)
+	}
+	m.slowest = pushSlowest(m.slowest, entry, r.size)
+}
+
+// Page returns the current content of the debug page.
+func (r *RPCz) Page() RPCzPage {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	page := RPCzPage{
+		InFlight: []RPCzEntry{},
+		Methods:  []RPCzMethod{},
+	}
+	for _, entry := range r.inFlight {
+		entry.Duration = now.Sub(entry.Start)
+		page.InFlight = append(page.InFlight, entry)
+	}
+	sort.Slice(page.InFlight, func(i, j int) bool {
+		return page.InFlight[i].Start.Before(page.InFlight[j].Start)
+	})
+
+	for procedure, m := range r.methods {
+		page.Methods = append(page.Methods, RPCzMethod{
+			Procedure: procedure,
+			Completed: append([]RPCzEntry{}, m.completed...),
+			Errored:   append([]RPCzEntry{}, m.errored...),
+			Slowest:   append([]RPCzEntry{}, m.slowest...),
+		})
+	}
+	sort.Slice(page.Methods, func(i, j int) bool {
+		return page.Methods[i].Procedure < page.Methods[j].Procedure
+	})
+
+	return page
+}
+
+func (r *RPCz) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	page := r.Page()
+
+	if req.URL.Query().Get("format") == "json" || strings.Contains(req.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(page)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = rpczTemplate.Execute(w, page)
+}
+
+type rpczMethod struct {
+	completed []RPCzEntry
+	errored   []RPCzEntry
+	slowest   []RPCzEntry
+}
+
+func newRPCzEntry(e *Event, duration time.Duration) RPCzEntry {
+	entry := RPCzEntry{
+		Procedure: e.Spec.Procedure,
+		Side:      sideOf(e.Spec),
+		Peer:      e.Peer.Addr,
+		Start:     e.Start,
+		Duration:  duration,
+		Code:      e.Code,
+	}
+	if e.Err != nil {
+		entry.Error = e.Err.Error()
+	}
+	return entry
+}
+
+func sideOf(spec connect.Spec) string {
+	if spec.IsClient {
+		return sideClient
+	}
+	return sideServer
+}
+
+// pushRecent prepends the entry, dropping the oldest entries over size.
+func pushRecent(entries []RPCzEntry, entry RPCzEntry, size int) []RPCzEntry {
+	entries = append([]RPCzEntry{entry}, entries...)
+	if len(entries) > size {
+		entries = entries[:size]
+	}
+	return entries
+}
+
+// pushSlowest inserts the entry ordered by duration, dropping the fastest entries over size.
+func pushSlowest(entries []RPCzEntry, entry RPCzEntry, size int) []RPCzEntry {
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].Duration < entry.Duration
+	})
+	if i >= size {
+		return entries
+	}
+
+	entries = append(entries, RPCzEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	if len(entries) > size {
+		entries = entries[:size]
+	}
+	return entries
+}
+
+var rpczTemplate = template.Must(template.New("rpcz").Parse(`<!DOCTYPE html>
+<html>
+<head><title>rpcz</title></head>
+<body>
+<h1>rpcz</h1>
+<h2>In-flight RPCs</h2>
+{{template "entries" .InFlight}}
+{{range .Methods}}
+<h2>{{.Procedure}}</h2>
+<h3>Recently completed</h3>
+{{template "entries" .Completed}}
+<h3>Recently errored</h3>
+{{template "entries" .Errored}}
+<h3>Slowest</h3>
+{{template "entries" .Slowest}}
+{{end}}
+</body>
+</html>
+{{define "entries"}}<table>
+<tr><th>Procedure</th><th>Side</th><th>Peer</th><th>Start</th><th>Duration</th><th>Code</th><th>Error</th></tr>
+{{range .}}<tr><td>{{.Procedure}}</td><td>{{.Side}}</td><td>{{.Peer}}</td><td>{{.Start.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{.Duration}}</td><td>{{.Code}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>{{end}}
+`))
+
+type rpczOptions struct {
+	size int
+}
+
+type RPCzOption func(*rpczOptions)
+
+// WithRPCzSize configures how many recently completed, errored and slowest RPCs are kept per method, at least 1.
+func WithRPCzSize(size int) RPCzOption {
+	return func(opts *rpczOptions) {
+		opts.size = size
+	}
+}
+
+func evaluateRPCzOptions(defaults *rpczOptions, opts ...RPCzOption) *rpczOptions {
+	for _, opt := range opts {
+		opt(defaults)
+	}
+	return defaults
+}
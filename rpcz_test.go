@@ -0,0 +1,151 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/stretchr/testify/require"
+)
+
+type blockingGreetServer struct {
+	greetconnect.UnimplementedGreetServiceHandler
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s blockingGreetServer) Greet(context.Context, *connect.Request[greet.GreetRequest]) (*connect.Response[greet.GreetResponse], error) {
+	s.started <- struct{}{}
+	<-s.release
+	return connect.NewResponse(&greet.GreetResponse{}), nil
+}
+
+func TestRPCz_InFlight(t *testing.T) {
+	rpcz := NewRPCz()
+	interceptor := NewInterceptor(WithClientMetrics(nil), WithServerMetrics(nil), WithServerReporter(rpcz))
+
+	server := blockingGreetServer{started: make(chan struct{}), release: make(chan struct{})}
+	_, handler := greetconnect.NewGreetServiceHandler(server, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL)
+	done := make(chan error)
+	go func() {
+		_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+		done <- err
+	}()
+
+	<-server.started
+	page := rpcz.Page()
+	require.Len(t, page.InFlight, 1)
+	require.Equal(t, "/greet.v1.GreetService/Greet", page.InFlight[0].Procedure)
+	require.Equal(t, "server", page.InFlight[0].Side)
+	require.NotEmpty(t, page.InFlight[0].Peer)
+	require.Empty(t, page.Methods)
+
+	close(server.release)
+	require.NoError(t, <-done)
+
+	page = rpcz.Page()
+	require.Empty(t, page.InFlight)
+	require.Len(t, page.Methods, 1)
+	require.Len(t, page.Methods[0].Completed, 1)
+	require.Equal(t, "ok", page.Methods[0].Completed[0].Code)
+}
+
+func TestRPCz_Recent(t *testing.T) {
+	rpcz := NewRPCz(WithRPCzSize(2))
+
+	handle := func(duration time.Duration, err error) {
+		e := newEvent(connect.Spec{Procedure: "/greet.v1.GreetService/Greet"}, connect.Peer{Addr: "127.0.0.1:1234"}, nil, nil)
+		rpcz.Started(context.Background(), e)
+		e.Duration = duration
+		e.Err = err
+		e.Code = codeOf(err)
+		rpcz.Handled(context.Background(), e)
+	}
+
+	handle(3*time.Second, nil)
+	handle(1*time.Second, nil)
+	handle(2*time.Second, nil)
+	handle(4*time.Second, connect.NewError(connect.CodeInternal, errors.New("boom")))
+
+	page := rpcz.Page()
+	require.Len(t, page.Methods, 1)
+	method := page.Methods[0]
+
+	durations := func(entries []RPCzEntry) []time.Duration {
+		var ds []time.Duration
+		for _, e := range entries {
+			ds = append(ds, e.Duration)
+		}
+		return ds
+	}
+	require.Equal(t, []time.Duration{2 * time.Second, 1 * time.Second}, durations(method.Completed), "most recent first, bounded")
+	require.Equal(t, []time.Duration{4 * time.Second, 3 * time.Second}, durations(method.Slowest), "slowest first, bounded")
+	require.Len(t, method.Errored, 1)
+	require.Equal(t, "internal", method.Errored[0].Code)
+	require.Equal(t, "internal: boom", method.Errored[0].Error)
+}
+
+func TestRPCz_InvalidSize(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		rpcz := NewRPCz(WithRPCzSize(size))
+		for i := 0; i < 2; i++ {
+			e := newEvent(connect.Spec{Procedure: "/greet.v1.GreetService/Greet"}, connect.Peer{}, nil, nil)
+			rpcz.Started(context.Background(), e)
+			rpcz.Handled(context.Background(), e)
+		}
+
+		page := rpcz.Page()
+		require.Len(t, page.Methods, 1)
+		require.Len(t, page.Methods[0].Completed, 1)
+	}
+}
+
+func TestRPCz_PageWhileHandled(t *testing.T) {
+	rpcz := NewRPCz()
+	e := newEvent(connect.Spec{Procedure: "/greet.v1.GreetService/Greet"}, connect.Peer{}, nil, nil)
+	rpcz.Started(context.Background(), e)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.handled(context.Background(), nil, connect.NewError(connect.CodeInternal, errors.New("boom")))
+	}()
+	page := rpcz.Page()
+	<-done
+
+	require.Len(t, page.InFlight, 1)
+	require.Empty(t, page.InFlight[0].Code)
+}
+
+func TestRPCz_ServeHTTP(t *testing.T) {
+	rpcz := NewRPCz()
+	e := newEvent(connect.Spec{Procedure: "/greet.v1.GreetService/Greet"}, connect.Peer{Addr: "127.0.0.1:1234"}, nil, nil)
+	rpcz.Started(context.Background(), e)
+	e.Code = "ok"
+	rpcz.Handled(context.Background(), e)
+
+	rec := httptest.NewRecorder()
+	rpcz.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/rpcz?format=json", nil))
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var page RPCzPage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Methods, 1)
+	require.Equal(t, "127.0.0.1:1234", page.Methods[0].Completed[0].Peer)
+
+	rec = httptest.NewRecorder()
+	rpcz.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/rpcz", nil))
+	require.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), "<h2>/greet.v1.GreetService/Greet</h2>")
+}
@@ -0,0 +1,217 @@
+package connect_go_prometheus
+
+import (
+	"sort"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Snapshot is a point in time copy of the RPCs reported to Metrics, see Metrics.Snapshot.
+type Snapshot struct {
+	// Procedures are sorted by service, method and type.
+	Procedures []ProcedureSnapshot
+}
+
+// Procedure returns the snapshot of the given service and method.
+func (s Snapshot) Procedure(service, method string) (ProcedureSnapshot, bool) {
+	for _, p := range s.Procedures {
+		if p.Service == service && p.Method == method {
+			return p, true
+		}
+	}
+	return ProcedureSnapshot{}, false
+}
+
+// ProcedureSnapshot are the counts of RPCs with the same type, service and method.
+type ProcedureSnapshot struct {
+	Type    string
+	Service string
+	Method  string
+
+	Started uint64
+	// Codes are sorted by code.
+	Codes []CodeSnapshot
+	// Latency across all codes, only set when the histogram is not labelled by code, see WithGRPCNaming.
+	Latency *LatencySnapshot
+}
+
+// Handled returns the number of handled RPCs, across all codes.
+func (p ProcedureSnapshot) Handled() uint64 {
+	var handled uint64
+	for _, c := range p.Codes {
+		handled += c.Handled
+	}
+	return handled
+}
+
+// Code returns the snapshot of RPCs handled with the given code.
+func (p ProcedureSnapshot) Code(code string) (CodeSnapshot, bool) {
+	for _, c := range p.Codes {
+		if c.Code == code {
+			return c, true
+		}
+	}
+	return CodeSnapshot{}, false
+}
+
+// CodeSnapshot are the counts of RPCs of a procedure handled with the same code.
+type CodeSnapshot struct {
+	Code    string
+	Handled uint64
+	// Latency is nil when the histogram is not enabled, see WithHistogram.
+	Latency *LatencySnapshot
+}
+
+// LatencySnapshot is the distribution of RPC latencies, in seconds.
+type LatencySnapshot struct {
+	Count uint64
+	Sum   float64
+	// Buckets are cumulative, sorted by upper bound.
+	Buckets []BucketSnapshot
+}
+
+// Mean returns the mean latency in seconds, or 0 without observations.
+func (l *LatencySnapshot) Mean() float64 {
+	if l.Count == 0 {
+		return 0
+	}
+	return l.Sum / float64(l.Count)
+}
+
+// BucketSnapshot is the cumulative count of RPCs with a latency up to UpperBound.
+type BucketSnapshot struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Snapshot returns the counts and latency distributions of RPCs reported so far, without going through a
+// prometheus registry. Types and codes are formatted as they are labelled, for example OK with WithGRPCNaming.
+// Latencies of a histogram without some labels, see WithoutHistogramLabels, are those of all procedures sharing the
+// labels it still has: without the method label, each procedure gets the latency of its whole service.
+func (m *Metrics) Snapshot() Snapshot {
+	procedures := map[snapshotKey]*ProcedureSnapshot{}
+	procedure := func(labels map[string]string) *ProcedureSnapshot {
+		k := snapshotKey{callType: labels[m.labels.typeName], service: labels[m.labels.serviceName], method: labels[m.labels.methodName]}
+		if _, ok := procedures[k]; !ok {
+			procedures[k] = &ProcedureSnapshot{Type: k.callType, Service: k.service, Method: k.method}
+		}
+		return procedures[k]
+	}
+
+	// Counters are summed, series of a procedure are split by caller with WithCallerLabel.
+	collectMetrics(m.requestStarted, func(labels map[string]string, metric *dto.Metric) {
+		procedure(labels).Started += uint64(metric.GetCounter().GetValue())
+	})
+
+	codes := map[*ProcedureSnapshot]map[string]*CodeSnapshot{}
+	code := func(p *ProcedureSnapshot, c string) *CodeSnapshot {
+		if codes[p] == nil {
+			codes[p] = map[string]*CodeSnapshot{}
+		}
+		if _, ok := codes[p][c]; !ok {
+			codes[p][c] = &CodeSnapshot{Code: c}
+		}
+		return codes[p][c]
+	}
+
+	collectMetrics(m.requestHandled, func(labels map[string]string, metric *dto.Metric) {
+		code(procedure(labels), labels[m.labels.codeName]).Handled += uint64(metric.GetCounter().GetValue())
+	})
+
+	if m.requestHandledSeconds != nil {
+		collectMetrics(m.requestHandledSeconds, func(labels map[string]string, metric *dto.Metric) {
+			c, byCode := labels[m.labels.codeName]
+			for _, p := range m.proceduresOf(labels, procedures, procedure) {
+				if byCode {
+					addLatency(&code(p, c).Latency, metric.GetHistogram())
+				} else {
+					addLatency(&p.Latency, metric.GetHistogram())
+				}
+			}
+		})
+	}
+
+	var snapshot Snapshot
+	for _, p := range procedures {
+		for _, c := range codes[p] {
+			p.Codes = append(p.Codes, *c)
+		}
+		sort.Slice(p.Codes, func(i, j int) bool {
+			return p.Codes[i].Code < p.Codes[j].Code
+		})
+		snapshot.Procedures = append(snapshot.Procedures, *p)
+	}
+	sort.Slice(snapshot.Procedures, func(i, j int) bool {
+		a, b := snapshot.Procedures[i], snapshot.Procedures[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Type < b.Type
+	})
+
+	return snapshot
+}
+
+type snapshotKey struct{ callType, service, method string }
+
+// proceduresOf returns the procedures a histogram series belongs to. When labels were dropped from the histogram
+// with WithoutHistogramLabels, the series belongs to all procedures sharing the labels it still has.
+func (m *Metrics) proceduresOf(labels map[string]string, procedures map[snapshotKey]*ProcedureSnapshot, procedure func(map[string]string) *ProcedureSnapshot) []*ProcedureSnapshot {
+	callType, hasType := labels[m.labels.typeName]
+	service, hasService := labels[m.labels.serviceName]
+	method, hasMethod := labels[m.labels.methodName]
+	if hasType && hasService && hasMethod {
+		return []*ProcedureSnapshot{procedure(labels)}
+	}
+
+	var matching []*ProcedureSnapshot
+	for k, p := range procedures {
+		if (!hasType || k.callType == callType) && (!hasService || k.service == service) && (!hasMethod || k.method == method) {
+			matching = append(matching, p)
+		}
+	}
+	return matching
+}
+
+// addLatency adds the observations of the histogram to the latency, which is created when nil. Series of a
+// procedure are split by caller with WithCallerLabel.
+func addLatency(latency **LatencySnapshot, histogram *dto.Histogram) {
+	if *latency == nil {
+		*latency = &LatencySnapshot{}
+		for _, b := range histogram.GetBucket() {
+			(*latency).Buckets = append((*latency).Buckets, BucketSnapshot{UpperBound: b.GetUpperBound()})
+		}
+	}
+
+	(*latency).Count += histogram.GetSampleCount()
+	(*latency).Sum += histogram.GetSampleSum()
+	for i, b := range histogram.GetBucket() {
+		(*latency).Buckets[i].Count += b.GetCumulativeCount()
+	}
+}
+
+// collectMetrics collects all metrics of a collector, calling fn with the labels and value of each.
+func collectMetrics(c prom.Collector, fn func(labels map[string]string, metric *dto.Metric)) {
+	ch := make(chan prom.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	for metric := range ch {
+		var pb dto.Metric
+		if err := metric.Write(&pb); err != nil {
+			continue
+		}
+
+		labels := make(map[string]string, len(pb.GetLabel()))
+		for _, pair := range pb.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		fn(labels, &pb)
+	}
+}
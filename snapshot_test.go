@@ -0,0 +1,124 @@
+package connect_go_prometheus
+
+import (
+	"testing"
+
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Snapshot(t *testing.T) {
+	sm := NewServerMetrics(
+		WithHistogram(true),
+		WithConstLabels(prom.Labels{"component": "foo"}),
+		WithHistogramBuckets([]float64{0.5, 1}),
+	)
+
+	sm.ReportStarted("unary", greetconnect.GreetServiceName, "Greet")
+	sm.ReportStarted("unary", greetconnect.GreetServiceName, "Greet")
+	sm.ReportStarted("server_stream", greetconnect.GreetServiceName, "ServerStreamGreet")
+	sm.ReportHandled("unary", greetconnect.GreetServiceName, "Greet", "ok")
+	sm.ReportHandledSeconds("unary", greetconnect.GreetServiceName, "Greet", "ok", 0.25)
+	sm.ReportHandled("unary", greetconnect.GreetServiceName, "Greet", "aborted")
+	sm.ReportHandledSeconds("unary", greetconnect.GreetServiceName, "Greet", "aborted", 0.75)
+
+	snapshot := sm.Snapshot()
+	require.Equal(t, Snapshot{
+		Procedures: []ProcedureSnapshot{
+			{
+				Type:    "unary",
+				Service: greetconnect.GreetServiceName,
+				Method:  "Greet",
+				Started: 2,
+				Codes: []CodeSnapshot{
+					{
+						Code:    "aborted",
+						Handled: 1,
+						Latency: &LatencySnapshot{Count: 1, Sum: 0.75, Buckets: []BucketSnapshot{{UpperBound: 0.5, Count: 0}, {UpperBound: 1, Count: 1}}},
+					},
+					{
+						Code:    "ok",
+						Handled: 1,
+						Latency: &LatencySnapshot{Count: 1, Sum: 0.25, Buckets: []BucketSnapshot{{UpperBound: 0.5, Count: 1}, {UpperBound: 1, Count: 1}}},
+					},
+				},
+			},
+			{
+				Type:    "server_stream",
+				Service: greetconnect.GreetServiceName,
+				Method:  "ServerStreamGreet",
+				Started: 1,
+			},
+		},
+	}, snapshot)
+
+	greet, ok := snapshot.Procedure(greetconnect.GreetServiceName, "Greet")
+	require.True(t, ok)
+	require.EqualValues(t, 2, greet.Handled())
+
+	okCode, ok := greet.Code("ok")
+	require.True(t, ok)
+	require.InDelta(t, 0.25, okCode.Latency.Mean(), 0.0001)
+
+	_, ok = snapshot.Procedure(greetconnect.GreetServiceName, "Unknown")
+	require.False(t, ok)
+}
+
+func TestMetrics_Snapshot_WithoutHistogram(t *testing.T) {
+	cm := NewClientMetrics()
+	cm.ReportStarted("unary", greetconnect.GreetServiceName, "Greet")
+	cm.ReportHandled("unary", greetconnect.GreetServiceName, "Greet", "ok")
+
+	greet, ok := cm.Snapshot().Procedure(greetconnect.GreetServiceName, "Greet")
+	require.True(t, ok)
+	require.EqualValues(t, 1, greet.Started)
+
+	code, ok := greet.Code("ok")
+	require.True(t, ok)
+	require.EqualValues(t, 1, code.Handled)
+	require.Nil(t, code.Latency)
+}
+
+func TestMetrics_Snapshot_WithoutHistogramLabels(t *testing.T) {
+	sm := NewServerMetrics(WithHistogram(true), WithoutHistogramLabels(LabelType, LabelMethod))
+	sm.ReportStarted("unary", greetconnect.GreetServiceName, "Greet")
+	sm.ReportHandled("unary", greetconnect.GreetServiceName, "Greet", "ok")
+	sm.ReportHandledSeconds("unary", greetconnect.GreetServiceName, "Greet", "ok", 0.25)
+	sm.ReportStarted("server_stream", greetconnect.GreetServiceName, "ServerStreamGreet")
+	sm.ReportHandled("server_stream", greetconnect.GreetServiceName, "ServerStreamGreet", "ok")
+	sm.ReportHandledSeconds("server_stream", greetconnect.GreetServiceName, "ServerStreamGreet", "ok", 0.75)
+
+	snapshot := sm.Snapshot()
+	require.Len(t, snapshot.Procedures, 2, "histogram series must not create procedures")
+
+	for _, method := range []string{"Greet", "ServerStreamGreet"} {
+		p, ok := snapshot.Procedure(greetconnect.GreetServiceName, method)
+		require.True(t, ok)
+		require.EqualValues(t, 1, p.Started)
+		require.EqualValues(t, 1, p.Handled())
+
+		code, ok := p.Code("ok")
+		require.True(t, ok)
+		require.EqualValues(t, 2, code.Latency.Count, "latency of the whole service")
+		require.InDelta(t, 0.5, code.Latency.Mean(), 0.0001)
+	}
+}
+
+func TestMetrics_Snapshot_WithoutHistogramType(t *testing.T) {
+	sm := NewServerMetrics(WithHistogram(true), WithoutHistogramLabels(LabelType))
+	sm.ReportStarted("unary", greetconnect.GreetServiceName, "Greet")
+	sm.ReportHandled("unary", greetconnect.GreetServiceName, "Greet", "ok")
+	sm.ReportHandledSeconds("unary", greetconnect.GreetServiceName, "Greet", "ok", 0.25)
+
+	snapshot := sm.Snapshot()
+	require.Len(t, snapshot.Procedures, 1)
+
+	greet, ok := snapshot.Procedure(greetconnect.GreetServiceName, "Greet")
+	require.True(t, ok)
+	require.Equal(t, "unary", greet.Type)
+	require.EqualValues(t, 1, greet.Started)
+	code, ok := greet.Code("ok")
+	require.True(t, ok)
+	require.EqualValues(t, 1, code.Latency.Count)
+}
@@ -0,0 +1,182 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/bufbuild/connect-go"
+)
+
+// Reasons a stream terminated, see Event.Reason.
+const (
+	streamReasonEOF              = "eof"
+	streamReasonClientCanceled   = "client_canceled"
+	streamReasonDeadlineExceeded = "deadline_exceeded"
+	streamReasonPeerGone         = "peer_gone"
+	streamReasonServerError      = "server_error"
+)
+
+// streamReasonOf classifies why a stream terminated. Transport errors of the stream, such as a connection reset,
+// mean the peer went away. Otherwise, the stream context distinguishes cancellation and deadlines from errors
+// returned by the server. A stream past its deadline is deadline_exceeded even if its context was canceled, as the
+// client drops the connection at its deadline.
+func streamReasonOf(ctx context.Context, err, transportErr error) string {
+	if transportErr != nil {
+		return streamReasonPeerGone
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		return streamReasonEOF
+	}
+	if isPeerGone(err) {
+		return streamReasonPeerGone
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || connect.CodeOf(err) == connect.CodeDeadlineExceeded || deadlinePassed(ctx) {
+		return streamReasonDeadlineExceeded
+	}
+	// A canceled code with a live context means the server canceled the stream on its own.
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return streamReasonClientCanceled
+	}
+	return streamReasonServerError
+}
+
+// isPeerGone reports whether the error is caused by the connection to the peer breaking mid-stream.
+func isPeerGone(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+// streamedMessagesOf returns the number of messages exchanged in the streaming directions of the RPC: requests of
+// client streams, responses of server streams and both for bidirectional streams.
+func streamedMessagesOf(e *Event) int {
+	requests, responses := messagesOf(e)
+	switch e.Spec.StreamType {
+	case connect.StreamTypeClient:
+		return requests
+	case connect.StreamTypeServer:
+		return responses
+	default:
+		return requests + responses
+	}
+}
+
+// messagesOf returns the number of request and response messages of a stream, from the messages sent and received
+// by this side.
+func messagesOf(e *Event) (requests, responses int) {
+	if e.Spec.IsClient {
+		return e.MessagesSent, e.MessagesReceived
+	}
+	return e.MessagesReceived, e.MessagesSent
+}
+
+// streamCounter counts the messages of a stream, and remembers the first transport error sending or receiving them.
+type streamCounter struct {
+	sent     atomic.Int64
+	received atomic.Int64
+
+	mu           sync.Mutex
+	transportErr error
+}
+
+func (c *streamCounter) observe(counter *atomic.Int64, err error) {
+	if err == nil {
+		counter.Add(1)
+		return
+	}
+	if isPeerGone(err) {
+		c.mu.Lock()
+		if c.transportErr == nil {
+			c.transportErr = err
+		}
+		c.mu.Unlock()
+	}
+}
+
+// end records the outcome of the stream on the event.
+func (c *streamCounter) end(ctx context.Context, e *Event, err error) {
+	c.mu.Lock()
+	transportErr := c.transportErr
+	c.mu.Unlock()
+
+	e.MessagesSent = int(c.sent.Load())
+	e.MessagesReceived = int(c.received.Load())
+	e.Reason = streamReasonOf(ctx, err, transportErr)
+}
+
+// reportingHandlerConn counts the messages of a server-side stream.
+type reportingHandlerConn struct {
+	connect.StreamingHandlerConn
+	counter *streamCounter
+}
+
+func (c *reportingHandlerConn) Send(msg any) error {
+	err := c.StreamingHandlerConn.Send(msg)
+	c.counter.observe(&c.counter.sent, err)
+	return err
+}
+
+func (c *reportingHandlerConn) Receive(msg any) error {
+	err := c.StreamingHandlerConn.Receive(msg)
+	if !errors.Is(err, io.EOF) {
+		c.counter.observe(&c.counter.received, err)
+	}
+	return err
+}
+
+// reportingClientConn counts the messages of a client-side stream, and reports the stream as handled once the
+// response ends, either by an error or io.EOF from Receive, or by CloseResponse.
+type reportingClientConn struct {
+	connect.StreamingClientConn
+	ctx     context.Context
+	counter *streamCounter
+	handled func(err error)
+	once    sync.Once
+}
+
+func (c *reportingClientConn) Send(msg any) error {
+	err := c.StreamingClientConn.Send(msg)
+	if !errors.Is(err, io.EOF) {
+		c.counter.observe(&c.counter.sent, err)
+	}
+	return err
+}
+
+func (c *reportingClientConn) Receive(msg any) error {
+	err := c.StreamingClientConn.Receive(msg)
+	if err == nil {
+		c.counter.observe(&c.counter.received, nil)
+		return nil
+	}
+
+	c.counter.observe(&c.counter.received, err)
+	if errors.Is(err, io.EOF) {
+		c.end(nil)
+	} else {
+		c.end(err)
+	}
+	return err
+}
+
+func (c *reportingClientConn) CloseResponse() error {
+	err := c.StreamingClientConn.CloseResponse()
+	// Closing the response before it ended abandons the stream, which is an error when the context is done.
+	if c.ctx.Err() != nil {
+		c.end(contextErrorOf(c.ctx))
+	} else {
+		c.end(nil)
+	}
+	return err
+}
+
+func (c *reportingClientConn) end(err error) {
+	c.once.Do(func() {
+		c.handled(err)
+	})
+}
@@ -0,0 +1,288 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// streamGreetServer sends messages on server streams, then blocks until the stream is done when block is set, or
+// returns err.
+type streamGreetServer struct {
+	greetconnect.UnimplementedGreetServiceHandler
+	messages int
+	block    bool
+	err      error
+}
+
+func (s streamGreetServer) ServerStreamGreet(ctx context.Context, _ *connect.Request[greet.GreetRequest], stream *connect.ServerStream[greet.GreetResponse]) error {
+	for i := 0; i < s.messages; i++ {
+		if err := stream.Send(&greet.GreetResponse{Greeting: "Hello"}); err != nil {
+			return err
+		}
+	}
+	if s.block {
+		<-ctx.Done()
+		// The client drops the connection at its deadline, which may cancel the context before the server's own
+		// deadline. Wait for it, as a handler doing work past the cancellation would.
+		if deadline, ok := ctx.Deadline(); ok {
+			time.Sleep(time.Until(deadline))
+		}
+		return ctx.Err()
+	}
+	return s.err
+}
+
+func (s streamGreetServer) ClientStreamGreet(_ context.Context, stream *connect.ClientStream[greet.GreetRequest]) (*connect.Response[greet.GreetResponse], error) {
+	for stream.Receive() {
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&greet.GreetResponse{Greeting: "Hello"}), nil
+}
+
+func TestInterceptor_ClientStream(t *testing.T) {
+	clientMetrics, serverMetrics := NewClientMetrics(), NewServerMetrics()
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(serverMetrics))
+
+	_, handler := greetconnect.NewGreetServiceHandler(streamGreetServer{}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	stream := client.ClientStreamGreet(context.Background())
+	for i := 0; i < 3; i++ {
+		require.NoError(t, stream.Send(&greet.GreetRequest{Name: "elza"}))
+	}
+	resp, err := stream.CloseAndReceive()
+	require.NoError(t, err)
+	require.Equal(t, "Hello", resp.Msg.Greeting)
+
+	for side, metrics := range map[string]*Metrics{"client": clientMetrics, "server": serverMetrics} {
+		require.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+			# HELP connect_`+side+`_handled_total Total number of RPCs handled `+side+`-side
+			# TYPE connect_`+side+`_handled_total counter
+			connect_`+side+`_handled_total{code="ok",method="ClientStreamGreet",service="greet.v1.GreetService",type="client_stream"} 1
+			# HELP connect_`+side+`_stream_terminated_total Total number of streams terminated `+side+`-side by reason, and whether messages were exchanged
+			# TYPE connect_`+side+`_stream_terminated_total counter
+			connect_`+side+`_stream_terminated_total{exchanged="true",method="ClientStreamGreet",reason="eof",service="greet.v1.GreetService",type="client_stream"} 1
+		`), "connect_"+side+"_handled_total", "connect_"+side+"_stream_terminated_total"))
+	}
+}
+
+func TestInterceptor_StreamMessages(t *testing.T) {
+	clientMetrics, serverMetrics := NewClientMetrics(), NewServerMetrics()
+	interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(serverMetrics))
+
+	_, handler := greetconnect.NewGreetServiceHandler(streamGreetServer{messages: 5}, connect.WithInterceptors(interceptor))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+	stream, err := client.ServerStreamGreet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+	require.NoError(t, err)
+	for stream.Receive() {
+	}
+	require.NoError(t, stream.Err())
+	require.NoError(t, stream.Close())
+
+	require.NoError(t, testutil.CollectAndCompare(serverMetrics, strings.NewReader(`
+		# HELP connect_server_msg_received_total Total number of stream messages recieved by server-side
+		# TYPE connect_server_msg_received_total counter
+		connect_server_msg_received_total{method="ServerStreamGreet",service="greet.v1.GreetService",type="server_stream"} 1
+		# HELP connect_server_msg_sent_total Total number of stream messages sent by server-side
+		# TYPE connect_server_msg_sent_total counter
+		connect_server_msg_sent_total{method="ServerStreamGreet",service="greet.v1.GreetService",type="server_stream"} 5
+	`), "connect_server_msg_sent_total", "connect_server_msg_received_total"))
+	require.NoError(t, testutil.CollectAndCompare(clientMetrics, strings.NewReader(`
+		# HELP connect_client_msg_recieved_total Total number of stream messages recieved by client-side
+		# TYPE connect_client_msg_recieved_total counter
+		connect_client_msg_recieved_total{method="ServerStreamGreet",service="greet.v1.GreetService",type="server_stream"} 5
+		# HELP connect_client_msg_sent_total Total number of stream messages sent by client-side
+		# TYPE connect_client_msg_sent_total counter
+		connect_client_msg_sent_total{method="ServerStreamGreet",service="greet.v1.GreetService",type="server_stream"} 1
+	`), "connect_client_msg_sent_total", "connect_client_msg_recieved_total"))
+}
+
+func TestInterceptor_StreamTermination(t *testing.T) {
+	for name, scenario := range map[string]struct {
+		server    streamGreetServer
+		ctx       func() (context.Context, context.CancelFunc)
+		cancel    bool
+		code      string
+		reason    string
+		exchanged string
+	}{
+		"eof": {
+			server:    streamGreetServer{messages: 2},
+			code:      "ok",
+			reason:    "eof",
+			exchanged: "true",
+		},
+		"server error": {
+			server:    streamGreetServer{err: connect.NewError(connect.CodeInternal, errors.New("boom"))},
+			code:      "internal",
+			reason:    "server_error",
+			exchanged: "false",
+		},
+		"server error mid-stream": {
+			server:    streamGreetServer{messages: 1, err: connect.NewError(connect.CodeInternal, errors.New("boom"))},
+			code:      "internal",
+			reason:    "server_error",
+			exchanged: "true",
+		},
+		"client canceled": {
+			server:    streamGreetServer{messages: 1, block: true},
+			cancel:    true,
+			reason:    "client_canceled",
+			exchanged: "true",
+		},
+		"deadline exceeded": {
+			server: streamGreetServer{block: true},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			code:      "deadline_exceeded",
+			reason:    "deadline_exceeded",
+			exchanged: "false",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clientMetrics, serverMetrics := NewClientMetrics(), NewServerMetrics()
+			interceptor := NewInterceptor(WithClientMetrics(clientMetrics), WithServerMetrics(serverMetrics))
+
+			_, handler := greetconnect.NewGreetServiceHandler(scenario.server, connect.WithInterceptors(interceptor))
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if scenario.ctx != nil {
+				ctx, cancel = scenario.ctx()
+			}
+			defer cancel()
+
+			client := greetconnect.NewGreetServiceClient(http.DefaultClient, srv.URL, connect.WithInterceptors(interceptor))
+			stream, err := client.ServerStreamGreet(ctx, connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+			require.NoError(t, err)
+
+			for stream.Receive() {
+				if scenario.cancel {
+					cancel()
+					break
+				}
+			}
+			if !scenario.cancel {
+				require.NoError(t, stream.Close())
+			} else {
+				require.Error(t, stream.Close())
+			}
+
+			expected := func(side string) string {
+				return `
+					# HELP connect_` + side + `_stream_terminated_total Total number of streams terminated ` + side + `-side by reason, and whether messages were exchanged
+					# TYPE connect_` + side + `_stream_terminated_total counter
+					connect_` + side + `_stream_terminated_total{exchanged="` + scenario.exchanged + `",method="ServerStreamGreet",reason="` + scenario.reason + `",service="greet.v1.GreetService",type="server_stream"} 1
+				`
+			}
+			require.NoError(t, testutil.CollectAndCompare(clientMetrics, strings.NewReader(expected("client")), "connect_client_stream_terminated_total"))
+
+			// The server handles the stream after the client is done with it.
+			require.Eventually(t, func() bool {
+				return testutil.CollectAndCompare(serverMetrics, strings.NewReader(expected("server")), "connect_server_stream_terminated_total") == nil
+			}, time.Second, 10*time.Millisecond)
+
+			if scenario.code != "" {
+				greetSnapshot, ok := serverMetrics.Snapshot().Procedure(greetconnect.GreetServiceName, "ServerStreamGreet")
+				require.True(t, ok)
+				code, ok := greetSnapshot.Code(scenario.code)
+				require.True(t, ok)
+				require.EqualValues(t, 1, code.Handled)
+			}
+		})
+	}
+}
+
+// canceledPastDeadline is a context canceled before its deadline timer fired, once the deadline has passed.
+type canceledPastDeadline struct {
+	context.Context
+}
+
+func newCanceledPastDeadline() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return canceledPastDeadline{Context: ctx}
+}
+
+func (canceledPastDeadline) Deadline() (time.Time, bool) {
+	return time.Now().Add(-time.Millisecond), true
+}
+
+func TestStreamReasonOf(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for name, scenario := range map[string]struct {
+		ctx          context.Context
+		err          error
+		transportErr error
+		expected     string
+	}{
+		"no error": {
+			ctx:      context.Background(),
+			expected: "eof",
+		},
+		"eof": {
+			ctx:      context.Background(),
+			err:      io.EOF,
+			expected: "eof",
+		},
+		"server error": {
+			ctx:      context.Background(),
+			err:      connect.NewError(connect.CodeUnavailable, errors.New("overloaded")),
+			expected: "server_error",
+		},
+		"canceled": {
+			ctx:      canceled,
+			err:      connect.NewError(connect.CodeCanceled, context.Canceled),
+			expected: "client_canceled",
+		},
+		"deadline exceeded": {
+			ctx:      context.Background(),
+			err:      connect.NewError(connect.CodeDeadlineExceeded, context.DeadlineExceeded),
+			expected: "deadline_exceeded",
+		},
+		"canceled past the deadline": {
+			ctx:      newCanceledPastDeadline(),
+			err:      connect.NewError(connect.CodeCanceled, context.Canceled),
+			expected: "deadline_exceeded",
+		},
+		"unexpected eof": {
+			ctx:      context.Background(),
+			err:      connect.NewError(connect.CodeUnknown, io.ErrUnexpectedEOF),
+			expected: "peer_gone",
+		},
+		"connection reset while sending": {
+			ctx:          canceled,
+			err:          connect.NewError(connect.CodeCanceled, context.Canceled),
+			transportErr: syscall.ECONNRESET,
+			expected:     "peer_gone",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, scenario.expected, streamReasonOf(scenario.ctx, scenario.err, scenario.transportErr))
+		})
+	}
+}
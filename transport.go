@@ -0,0 +1,188 @@
+package connect_go_prometheus
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	transportBuckets = prom.ExponentialBuckets(0.0005, 2, 14)
+)
+
+// WrapTransport instruments the http.RoundTripper used by connect clients with the client metrics configured with
+// WithClientMetrics, by default DefaultClientMetrics. Per target host, it reports DNS, connect and TLS handshake
+// latency, connection reuse, the HTTP protocol version and the time to first response byte. When next is nil,
+// http.DefaultTransport is used.
+//
+//	httpClient := &http.Client{Transport: connect_go_prometheus.WrapTransport(http.DefaultTransport)}
+//	client := greetconnect.NewGreetServiceClient(httpClient, serverURL, connect.WithInterceptors(interceptor))
+func WrapTransport(next http.RoundTripper, opts ...InterecptorOption) http.RoundTripper {
+	options := evaluteInterceptorOptions(&interceptorOptions{
+		client: DefaultClientMetrics,
+		server: DefaultServerMetrics,
+	}, opts...)
+
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if options.client == nil || options.client.transport == nil {
+		return next
+	}
+
+	return &instrumentedTransport{
+		next:    next,
+		metrics: options.client.transport,
+	}
+}
+
+type instrumentedTransport struct {
+	next    http.RoundTripper
+	metrics *transportMetrics
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	start := time.Now()
+
+	var (
+		mu           sync.Mutex
+		dnsStart     time.Time
+		tlsStart     time.Time
+		connectStart = map[string]time.Time{}
+	)
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if info.Err == nil && !dnsStart.IsZero() {
+				t.metrics.dns.WithLabelValues(host).Observe(time.Since(dnsStart).Seconds())
+			}
+		},
+		// Dials to multiple addresses may run concurrently, see RFC 6555.
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			defer mu.Unlock()
+			connectStart[network+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if began, ok := connectStart[network+addr]; ok && err == nil {
+				t.metrics.connect.WithLabelValues(host).Observe(time.Since(began).Seconds())
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil && !tlsStart.IsZero() {
+				t.metrics.tlsHandshake.WithLabelValues(host).Observe(time.Since(tlsStart).Seconds())
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.metrics.connections.WithLabelValues(host, strconv.FormatBool(info.Reused)).Inc()
+		},
+		GotFirstResponseByte: func() {
+			t.metrics.firstByte.WithLabelValues(host).Observe(time.Since(start).Seconds())
+		},
+	}
+
+	resp, err := t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		return resp, err
+	}
+
+	t.metrics.requests.WithLabelValues(host, resp.Proto).Inc()
+	return resp, nil
+}
+
+// transportMetrics are reported by the transport returned from WrapTransport.
+type transportMetrics struct {
+	dns          *prom.HistogramVec
+	connect      *prom.HistogramVec
+	tlsHandshake *prom.HistogramVec
+	firstByte    *prom.HistogramVec
+	connections  *prom.CounterVec
+	requests     *prom.CounterVec
+}
+
+func newTransportMetrics(config *metricsOptions) *transportMetrics {
+	histogram := func(name, help string) *prom.HistogramVec {
+		return prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace:   config.namespace,
+			Subsystem:   config.subsystem,
+			ConstLabels: config.constLabels,
+			Name:        name,
+			Help:        help,
+			Buckets:     transportBuckets,
+		}, []string{"host"})
+	}
+
+	return &transportMetrics{
+		dns:          histogram(config.transportDNSName, "Histogram of DNS lookup latency client-side"),
+		connect:      histogram(config.transportConnectName, "Histogram of connection dial latency client-side"),
+		tlsHandshake: histogram(config.transportTLSHandshakeName, "Histogram of TLS handshake latency client-side"),
+		firstByte:    histogram(config.transportFirstByteName, "Histogram of latency to the first response byte client-side"),
+		connections: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   config.namespace,
+			Subsystem:   config.subsystem,
+			ConstLabels: config.constLabels,
+			Name:        config.transportConnectionsName,
+			Help:        "Total number of connections obtained client-side, by whether they were reused",
+		}, []string{"host", "reused"}),
+		requests: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   config.namespace,
+			Subsystem:   config.subsystem,
+			ConstLabels: config.constLabels,
+			Name:        config.transportRequestsName,
+			Help:        "Total number of HTTP requests sent client-side, by HTTP protocol version",
+		}, []string{"host", "protocol"}),
+	}
+}
+
+// Describe implements Describe as required by prom.Collector
+func (m *transportMetrics) Describe(c chan<- *prom.Desc) {
+	m.dns.Describe(c)
+	m.connect.Describe(c)
+	m.tlsHandshake.Describe(c)
+	m.firstByte.Describe(c)
+	m.connections.Describe(c)
+	m.requests.Describe(c)
+}
+
+// Collect implements collect as required by prom.Collector
+func (m *transportMetrics) Collect(c chan<- prom.Metric) {
+	m.dns.Collect(c)
+	m.connect.Collect(c)
+	m.tlsHandshake.Collect(c)
+	m.firstByte.Collect(c)
+	m.connections.Collect(c)
+	m.requests.Collect(c)
+}
+
+// Reset deletes all metrics.
+func (m *transportMetrics) Reset() {
+	m.dns.Reset()
+	m.connect.Reset()
+	m.tlsHandshake.Reset()
+	m.firstByte.Reset()
+	m.connections.Reset()
+	m.requests.Reset()
+}
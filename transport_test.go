@@ -0,0 +1,58 @@
+package connect_go_prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet"
+	"github.com/easyCZ/connect-go-prometheus/gen/greet/greetconnect"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapTransport(t *testing.T) {
+	reg := prom.NewRegistry()
+	clientMetrics := NewClientMetrics()
+	reg.MustRegister(clientMetrics)
+
+	_, handler := greetconnect.NewGreetServiceHandler(greetServer{})
+	srv := httptest.NewUnstartedServer(handler)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	host := u.Host
+
+	httpClient := srv.Client()
+	httpClient.Transport = WrapTransport(httpClient.Transport, WithClientMetrics(clientMetrics))
+
+	client := greetconnect.NewGreetServiceClient(httpClient, srv.URL)
+	for i := 0; i < 2; i++ {
+		_, err := client.Greet(context.Background(), connect.NewRequest(&greet.GreetRequest{Name: "elza"}))
+		require.NoError(t, err)
+	}
+
+	transport := clientMetrics.transport
+	require.EqualValues(t, 1, testutil.ToFloat64(transport.connections.WithLabelValues(host, "false")))
+	require.EqualValues(t, 1, testutil.ToFloat64(transport.connections.WithLabelValues(host, "true")))
+	require.EqualValues(t, 2, testutil.ToFloat64(transport.requests.WithLabelValues(host, "HTTP/2.0")))
+
+	count, err := testutil.GatherAndCount(reg,
+		"connect_client_connect_seconds",
+		"connect_client_tls_handshake_seconds",
+		"connect_client_first_byte_seconds",
+	)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+}
+
+func TestWrapTransport_WithoutClientMetrics(t *testing.T) {
+	require.Equal(t, http.DefaultTransport, WrapTransport(nil, WithClientMetrics(nil)))
+}